@@ -0,0 +1,163 @@
+package main
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"TrainTicketsTool/internal/processor"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const inspectCommand = "inspect"
+
+type inspectReport struct {
+	Source string `json:"source"`
+	invoice.Inspection
+}
+
+func runInspect(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("invoicecli inspect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var jsonMode bool
+	var passwords stringList
+	fs.BoolVar(&jsonMode, "json", false, "以 JSON 输出检查结果")
+	fs.Var(&passwords, "pdf-password", "加密 PDF 的打开密码（可重复指定多个）")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, "参数错误:", err)
+		return exitFatal
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "参数错误: 用法 invoicecli inspect [-json] [-pdf-password 密码] 文件.pdf|文件.zip!条目.pdf")
+		return exitFatal
+	}
+
+	src := processor.ParseSourceRef(fs.Arg(0))
+	b, err := processor.ReadSourceBytes(src)
+	if err != nil {
+		fmt.Fprintln(stderr, "读取文件失败:", err)
+		return exitFatal
+	}
+	rep := inspectReport{Source: src.String(), Inspection: invoice.InspectPDF(ctx, b, passwords)}
+	if jsonMode {
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			fmt.Fprintln(stderr, "输出 JSON 失败:", err)
+			return exitFatal
+		}
+	} else {
+		printInspection(stdout, rep)
+	}
+	if ctx.Err() != nil {
+		return exitCanceled
+	}
+	if rep.Info == nil {
+		return exitPartialFailure
+	}
+	return exitOK
+}
+
+func printInspection(w io.Writer, rep inspectReport) {
+	fmt.Fprintln(w, "来源:", rep.Source)
+	version := rep.Version
+	if version == "" {
+		version = "未知"
+	}
+	if rep.Encrypted {
+		version += "（已加密）"
+	}
+	fmt.Fprintln(w, "PDF 版本:", version)
+
+	fmt.Fprintf(w, "内嵌文件: %d 个\n", len(rep.EmbeddedFiles))
+	for _, f := range rep.EmbeddedFiles {
+		filters := strings.Join(f.Filters, ",")
+		if filters == "" {
+			filters = "无过滤器"
+		}
+		line := fmt.Sprintf("  对象 %d  %s  %s  原始 %d 字节", f.Object, displayName(f.Name), filters, f.Length)
+		if f.Error != "" {
+			line += "  解码失败: " + f.Error
+		} else {
+			line += fmt.Sprintf("  解码后 %d 字节", f.Size)
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintln(w, "步骤:")
+	for _, s := range rep.Steps {
+		line := "  " + s.Step
+		if s.Object > 0 || s.Name != "" {
+			line += fmt.Sprintf("（对象 %d %s）", s.Object, displayName(s.Name))
+		}
+		if s.Error != "" {
+			line += "  失败: " + s.Error
+		} else {
+			line += "  成功"
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	switch rep.XbrlSource {
+	case invoice.XbrlSourcePlain:
+		fmt.Fprintln(w, "XBRL 位置: 明文")
+	case invoice.XbrlSourceEmbedded:
+		fmt.Fprintf(w, "XBRL 位置: EmbeddedFile 对象 %d\n", rep.XbrlObject)
+	default:
+		fmt.Fprintln(w, "XBRL 位置: 未找到")
+	}
+
+	if len(rep.Facts) > 0 {
+		fmt.Fprintf(w, "事实: %d 条\n", len(rep.Facts))
+		for _, f := range rep.Facts {
+			fmt.Fprintln(w, "  "+factLine(f))
+		}
+	}
+	if rep.Info != nil {
+		b, _ := json.MarshalIndent(rep.Info, "  ", "  ")
+		fmt.Fprintln(w, "发票字段:")
+		fmt.Fprintln(w, "  "+string(b))
+	}
+	if rep.Xbrl != "" {
+		fmt.Fprintln(w, "XBRL 原文:")
+		fmt.Fprintln(w, rep.Xbrl)
+	}
+}
+
+func factLine(f invoice.XbrlFact) string {
+	name := f.Name
+	if f.Namespace != "" {
+		name = "{" + f.Namespace + "}" + name
+	}
+	var attrs []string
+	if f.ContextRef != "" {
+		attrs = append(attrs, "context="+f.ContextRef)
+	}
+	if f.UnitRef != "" {
+		attrs = append(attrs, "unit="+f.UnitRef)
+	}
+	if f.Decimals != "" {
+		attrs = append(attrs, "decimals="+f.Decimals)
+	}
+	if f.Nil {
+		attrs = append(attrs, "nil")
+	}
+	if len(attrs) > 0 {
+		name += " [" + strings.Join(attrs, " ") + "]"
+	}
+	return name + " = " + f.Value
+}
+
+func displayName(name string) string {
+	if name == "" {
+		return "（无文件名）"
+	}
+	return name
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"TrainTicketsTool/internal/processor"
+	"encoding/json"
+	"io"
+)
+
+type fileResult struct {
+	Status  string               `json:"status"`
+	Source  string               `json:"source,omitempty"`
+	Output  string               `json:"output,omitempty"`
+	Message string               `json:"message,omitempty"`
+	Error   string               `json:"error,omitempty"`
+	Info    *invoice.InvoiceInfo `json:"info,omitempty"`
+}
+
+type jsonReport struct {
+	Summary processor.Summary     `json:"summary"`
+	Error   string                `json:"error,omitempty"`
+	Results []fileResult          `json:"results"`
+	Plan    []processor.PlannedOp `json:"plan,omitempty"`
+}
+
+type streamSummary struct {
+	Status  string            `json:"status"`
+	Summary processor.Summary `json:"summary"`
+	Error   string            `json:"error,omitempty"`
+}
+
+func newFileResult(e processor.Event) fileResult {
+	res := fileResult{
+		Status:  e.Kind.String(),
+		Output:  e.OutputPath,
+		Message: e.Message,
+		Info:    e.Info,
+	}
+	if e.Kind != processor.EventInfo {
+		res.Source = e.Source.String()
+	}
+	if e.Err != nil {
+		res.Error = e.Err.Error()
+	}
+	return res
+}
+
+func writeJSONReport(w io.Writer, sum processor.Summary, results []fileResult, plan []processor.PlannedOp, runErr error) error {
+	report := jsonReport{
+		Summary: sum,
+		Results: results,
+		Plan:    plan,
+	}
+	if report.Results == nil {
+		report.Results = []fileResult{}
+	}
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func newStreamSummary(sum processor.Summary, runErr error) streamSummary {
+	res := streamSummary{Status: "summary", Summary: sum}
+	if runErr != nil {
+		res.Error = runErr.Error()
+	}
+	return res
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
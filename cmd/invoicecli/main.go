@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+const (
+	exitOK             = 0
+	exitPartialFailure = 1
+	exitFatal          = 2
+	exitCanceled       = 130
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
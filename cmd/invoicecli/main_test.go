@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testXbrl = `<xbrl xmlns:rai="urn:rai"><rai:TravelDate>2026-02-24</rai:TravelDate><rai:DateOfIssue>2026-02-28</rai:DateOfIssue><rai:DepartureStation>郑州东</rai:DepartureStation><rai:DestinationStation>三门峡南</rai:DestinationStation></xbrl>`
+
+const testOutName = "2026-02-24-郑州东-三门峡南.pdf"
+
+var (
+	testPDF    = []byte("%PDF-1.7\nstream\n" + testXbrl + "\nendstream\n%%EOF\n")
+	testBadPDF = []byte("%PDF-1.7\n%%EOF\n")
+)
+
+func runCLI(t *testing.T, ctx context.Context, args []string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeInputs(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestRun_FlagsAndExitCodes(t *testing.T) {
+	cases := []struct {
+		name       string
+		args       []string
+		files      map[string][]byte
+		canceled   bool
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{name: "help", args: []string{"-h"}, wantCode: exitOK, wantStderr: "-input"},
+		{name: "unknown flag", args: []string{"-nope"}, wantCode: exitFatal, wantStderr: "参数错误"},
+		{name: "extra args", args: []string{"-input", "{in}", "-output", "{out}", "stray"}, wantCode: exitFatal, wantStderr: "多余的参数: stray"},
+		{name: "missing input", args: []string{"-output", "{out}"}, wantCode: exitFatal, wantStderr: "未指定 -input"},
+		{name: "missing output", args: []string{"-input", "{in}"}, wantCode: exitFatal, wantStderr: "未指定 -output"},
+		{name: "bad date field", args: []string{"-input", "{in}", "-output", "{out}", "-date", "x"}, wantCode: exitFatal, wantStderr: "未知日期字段"},
+		{name: "bad dedup", args: []string{"-input", "{in}", "-output", "{out}", "-dedup", "x"}, wantCode: exitFatal, wantStderr: "未知去重方式"},
+		{name: "bad transfer", args: []string{"-input", "{in}", "-output", "{out}", "-transfer", "x"}, wantCode: exitFatal, wantStderr: "未知传输方式"},
+		{name: "rename with dir template", args: []string{"-input", "{in}", "-output", "{out}", "-transfer", "rename", "-dir", "{passenger}"}, wantCode: exitFatal, wantStderr: "-transfer rename 不能与 -dir"},
+		{name: "apply with dry run", args: []string{"-apply-plan", "p.json", "-dry-run"}, wantCode: exitFatal, wantStderr: "-apply-plan 不能与"},
+		{name: "watch with report", args: []string{"-input", "{in}", "-output", "{out}", "-watch", "-report", "r.csv"}, wantCode: exitFatal, wantStderr: "-watch 不能与"},
+		{name: "undo without output", args: []string{"-undo", "latest"}, wantCode: exitFatal, wantStderr: "需要指定 -output"},
+		{name: "success", args: []string{"-input", "{in}", "-output", "{out}"}, files: map[string][]byte{"a.pdf": testPDF}, wantCode: exitOK, wantStdout: "发现 PDF：1  成功：1  失败：0"},
+		{name: "partial failure", args: []string{"-input", "{in}", "-output", "{out}"}, files: map[string][]byte{"a.pdf": testPDF, "b.pdf": testBadPDF}, wantCode: exitPartialFailure, wantStdout: "成功：1  失败：1"},
+		{name: "dry run", args: []string{"-input", "{in}", "-output", "{out}", "-dry-run"}, files: map[string][]byte{"a.pdf": testPDF}, wantCode: exitOK, wantStdout: "PLAN:"},
+		{name: "canceled", args: []string{"-input", "{in}", "-output", "{out}"}, files: map[string][]byte{"a.pdf": testPDF}, canceled: true, wantCode: exitCanceled},
+		{name: "watch stopped", args: []string{"-input", "{in}", "-output", "{out}", "-watch"}, canceled: true, wantCode: exitOK, wantStdout: "开始监视输入目录"},
+		{name: "inspect", args: []string{"inspect", "{in}/a.pdf"}, files: map[string][]byte{"a.pdf": testPDF}, wantCode: exitOK, wantStdout: "郑州东"},
+		{name: "inspect unreadable", args: []string{"inspect", "{in}/a.pdf"}, files: map[string][]byte{"a.pdf": testBadPDF}, wantCode: exitPartialFailure},
+		{name: "inspect missing file", args: []string{"inspect", "{in}/none.pdf"}, wantCode: exitFatal, wantStderr: "读取文件失败"},
+	}
+	for _, tc := range cases {
+		inDir := writeInputs(t, tc.files)
+		outDir := t.TempDir()
+		args := make([]string, len(tc.args))
+		for i, a := range tc.args {
+			args[i] = strings.NewReplacer("{in}", inDir, "{out}", outDir).Replace(a)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		if tc.canceled {
+			cancel()
+		}
+		code, stdout, stderr := runCLI(t, ctx, args)
+		cancel()
+		if code != tc.wantCode {
+			t.Fatalf("%s: exit code %d, want %d\nstdout:\n%s\nstderr:\n%s", tc.name, code, tc.wantCode, stdout, stderr)
+		}
+		if !strings.Contains(stdout, tc.wantStdout) || !strings.Contains(stderr, tc.wantStderr) {
+			t.Fatalf("%s: unexpected output\nstdout:\n%s\nstderr:\n%s", tc.name, stdout, stderr)
+		}
+	}
+}
+
+func TestRun_JSONOutput(t *testing.T) {
+	inDir := writeInputs(t, map[string][]byte{"a.pdf": testPDF, "b.pdf": testBadPDF})
+	outDir := t.TempDir()
+	code, stdout, stderr := runCLI(t, context.Background(), []string{"-input", inDir, "-output", outDir, "-json", "-jobs", "1"})
+	if code != exitPartialFailure || stderr != "" {
+		t.Fatalf("exit code %d stderr=%q", code, stderr)
+	}
+	var rep jsonReport
+	if err := json.Unmarshal([]byte(stdout), &rep); err != nil {
+		t.Fatalf("stdout is not a JSON report: %v\n%s", err, stdout)
+	}
+	if rep.Summary.FoundPDF != 2 || rep.Summary.Succeeded != 1 || rep.Summary.Failed != 1 || rep.Error != "" {
+		t.Fatalf("unexpected summary: %+v error=%q", rep.Summary, rep.Error)
+	}
+	statuses := make(map[string]fileResult)
+	for _, r := range rep.Results {
+		statuses[r.Status] = r
+	}
+	ok, failed := statuses["ok"], statuses["error"]
+	if filepath.Base(ok.Output) != testOutName || ok.Info == nil || ok.Info.DepartureStation != "郑州东" {
+		t.Fatalf("unexpected ok result: %+v", ok)
+	}
+	if filepath.Base(failed.Source) != "b.pdf" || failed.Error == "" {
+		t.Fatalf("unexpected error result: %+v", failed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	code, stdout, _ = runCLI(t, ctx, []string{"-input", inDir, "-output", t.TempDir(), "-watch", "-json"})
+	if code != exitOK {
+		t.Fatalf("stopped watch exit code %d", code)
+	}
+	var lines []map[string]any
+	sc := bufio.NewScanner(strings.NewReader(stdout))
+	for sc.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("watch output must be one JSON object per line: %v\n%s", err, stdout)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) < 2 || lines[0]["status"] != "info" || lines[len(lines)-1]["status"] != "summary" || lines[len(lines)-1]["error"] != nil {
+		t.Fatalf("unexpected watch lines: %v", lines)
+	}
+}
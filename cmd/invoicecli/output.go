@@ -0,0 +1,57 @@
+package main
+
+import (
+	"TrainTicketsTool/internal/processor"
+	"fmt"
+	"io"
+)
+
+type output struct {
+	jsonMode bool
+	stream   bool
+	w        io.Writer
+	errW     io.Writer
+	results  []fileResult
+}
+
+func newOutput(jsonMode bool, stdout io.Writer, stderr io.Writer) *output {
+	return &output{jsonMode: jsonMode, w: stdout, errW: stderr}
+}
+
+func (o *output) onEvent(e processor.Event) {
+	if !o.jsonMode {
+		fmt.Fprintln(o.w, e.LogLine())
+		return
+	}
+	if o.stream {
+		if err := writeJSONLine(o.w, newFileResult(e)); err != nil {
+			fmt.Fprintln(o.errW, "输出 JSON 失败:", err)
+		}
+		return
+	}
+	o.results = append(o.results, newFileResult(e))
+}
+
+func (o *output) finish(sum processor.Summary, plan []processor.PlannedOp, runErr error) int {
+	if o.stream {
+		if err := writeJSONLine(o.w, newStreamSummary(sum, runErr)); err != nil {
+			fmt.Fprintln(o.errW, "输出 JSON 失败:", err)
+			return exitFatal
+		}
+	} else if o.jsonMode {
+		if err := writeJSONReport(o.w, sum, o.results, plan, runErr); err != nil {
+			fmt.Fprintln(o.errW, "输出 JSON 失败:", err)
+			return exitFatal
+		}
+	} else {
+		printSummary(o.w, sum, runErr)
+	}
+	return exitCode(sum, runErr)
+}
+
+func printSummary(w io.Writer, sum processor.Summary, runErr error) {
+	if runErr != nil {
+		fmt.Fprintln(w, "处理失败:", runErr)
+	}
+	fmt.Fprintf(w, "发现 PDF：%d  成功：%d  失败：%d\n", sum.FoundPDF, sum.Succeeded, sum.Failed)
+}
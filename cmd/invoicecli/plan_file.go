@@ -0,0 +1,31 @@
+package main
+
+import (
+	"TrainTicketsTool/internal/processor"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const planFileMode = 0o644
+
+func savePlan(path string, plan processor.Plan) error {
+	b, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化计划失败: %w", err)
+	}
+	b = append(b, '\n')
+	return os.WriteFile(path, b, planFileMode)
+}
+
+func loadPlan(path string) (processor.Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return processor.Plan{}, err
+	}
+	var plan processor.Plan
+	if err := json.Unmarshal(b, &plan); err != nil {
+		return processor.Plan{}, fmt.Errorf("解析计划失败: %w", err)
+	}
+	return plan, nil
+}
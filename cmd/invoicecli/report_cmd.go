@@ -0,0 +1,72 @@
+package main
+
+import (
+	"TrainTicketsTool/internal/processor"
+	"TrainTicketsTool/internal/report"
+	"TrainTicketsTool/internal/vat"
+	"context"
+	"fmt"
+	"strings"
+)
+
+func runWithReport(ctx context.Context, out *output, cfg processor.Config, opts runOptions) int {
+	reportOpts, err := reportOptions(opts)
+	if err != nil {
+		fmt.Fprintln(out.errW, "参数错误:", err)
+		return exitFatal
+	}
+	var collector report.Collector
+	sum, runErr := processor.RunWithEvents(ctx, cfg, collector.Handler(out.onEvent))
+	if err := writeReport(out, opts.reportPath, collector.Rows(), reportOpts); err != nil {
+		return exitFatal
+	}
+	return out.finish(sum, nil, runErr)
+}
+
+func reportFromOutputDir(ctx context.Context, out *output, opts runOptions) int {
+	reportOpts, err := reportOptions(opts)
+	if err != nil {
+		fmt.Fprintln(out.errW, "参数错误:", err)
+		return exitFatal
+	}
+	rows, sum, scanErr := report.ScanOutputDir(ctx, opts.outputDir, opts.pdfPasswords, out.onEvent)
+	if scanErr == nil {
+		if err := writeReport(out, opts.reportPath, rows, reportOpts); err != nil {
+			return exitFatal
+		}
+	}
+	return out.finish(sum, nil, scanErr)
+}
+
+func reportOptions(opts runOptions) (report.Options, error) {
+	field, err := parseDateField(opts.dateField)
+	if err != nil {
+		return report.Options{}, err
+	}
+	period, err := parseVATPeriod(opts.vatPeriod)
+	if err != nil {
+		return report.Options{}, err
+	}
+	return report.Options{DateField: field, VATPeriod: period}, nil
+}
+
+func parseVATPeriod(s string) (vat.Period, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	for _, p := range []vat.Period{vat.PeriodMonth, vat.PeriodQuarter} {
+		if v == p.String() {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("未知抵扣期间: %q", s)
+}
+
+func writeReport(out *output, path string, rows []report.Row, opts report.Options) error {
+	if err := report.WriteFile(path, report.Build(rows, opts)); err != nil {
+		fmt.Fprintln(out.errW, "生成报表失败:", err)
+		return err
+	}
+	if !out.jsonMode {
+		fmt.Fprintf(out.w, "报表已生成：%s（%d 张）\n", path, len(rows))
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"TrainTicketsTool/internal/processor"
+	"TrainTicketsTool/internal/report"
+	"TrainTicketsTool/internal/vat"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	dateFlagTravel = "travel"
+	dateFlagIssue  = "issue"
+)
+
+type runOptions struct {
+	inputDir     string
+	outputDir    string
+	dateField    string
+	nameTemplate string
+	dirTemplate  string
+	dedupMode    string
+	transferMode string
+	fileTimeout  time.Duration
+	jobs         int
+	jsonMode     bool
+	incremental  bool
+
+	dryRun    bool
+	savePlan  string
+	applyPlan string
+
+	undoRun  string
+	listRuns bool
+
+	watch         bool
+	watchInterval time.Duration
+
+	reportPath string
+	vatPeriod  string
+
+	pdfPasswords  stringList
+	rejectTextPDF bool
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == inspectCommand {
+		return runInspect(ctx, args[1:], stdout, stderr)
+	}
+
+	opts, err := parseRunFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, "参数错误:", err)
+		return exitFatal
+	}
+
+	out := newOutput(opts.jsonMode, stdout, stderr)
+	if opts.listRuns {
+		return listRuns(out, opts.outputDir)
+	}
+	if opts.undoRun != "" {
+		sum, undoErr := processor.UndoRunWithEvents(ctx, opts.outputDir, opts.undoRun, out.onEvent)
+		return out.finish(sum, nil, undoErr)
+	}
+	if opts.reportPath != "" && strings.TrimSpace(opts.inputDir) == "" {
+		return reportFromOutputDir(ctx, out, opts)
+	}
+	if opts.applyPlan != "" {
+		plan, err := loadPlan(opts.applyPlan)
+		if err != nil {
+			fmt.Fprintln(stderr, "读取计划失败:", err)
+			return exitFatal
+		}
+		sum, applyErr := processor.ApplyPlanWithEvents(ctx, plan, out.onEvent)
+		return out.finish(sum, nil, applyErr)
+	}
+
+	cfg, err := buildConfig(opts)
+	if err != nil {
+		fmt.Fprintln(stderr, "参数错误:", err)
+		return exitFatal
+	}
+
+	if opts.dryRun || opts.savePlan != "" {
+		plan, planErr := processor.BuildPlanWithEvents(ctx, cfg, out.onEvent)
+		if planErr == nil && opts.savePlan != "" {
+			if err := savePlan(opts.savePlan, plan); err != nil {
+				fmt.Fprintln(stderr, "保存计划失败:", err)
+				return exitFatal
+			}
+		}
+		return out.finish(plan.Summary, plan.Ops, planErr)
+	}
+
+	if opts.watch {
+		out.stream = opts.jsonMode
+		sum, watchErr := processor.Watch(ctx, cfg, opts.watchInterval, out.onEvent)
+		if errors.Is(watchErr, processor.ErrCanceled) {
+			watchErr = nil
+		}
+		return out.finish(sum, nil, watchErr)
+	}
+	if opts.reportPath != "" {
+		return runWithReport(ctx, out, cfg, opts)
+	}
+	sum, runErr := processor.RunWithEvents(ctx, cfg, out.onEvent)
+	return out.finish(sum, nil, runErr)
+}
+
+func parseRunFlags(args []string, errOut io.Writer) (runOptions, error) {
+	fs := flag.NewFlagSet("invoicecli", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+
+	opts := runOptions{}
+	fs.StringVar(&opts.inputDir, "input", "", "输入目录（包含发票 PDF / ZIP）")
+	fs.StringVar(&opts.outputDir, "output", "", "输出目录")
+	fs.StringVar(&opts.dateField, "date", dateFlagTravel, "日期字段：travel（乘车日期）或 issue（开票日期）")
+	fs.StringVar(&opts.nameTemplate, "name", processor.DefaultNameTemplate, "输出文件名模板，例如 {travelDate:yyyyMMdd}_{from}至{to}.pdf")
+	fs.StringVar(&opts.dirTemplate, "dir", "", "输出子目录模板，例如 {date:yyyy}/{date:yyyy-MM} 或 {passenger}（默认不分子目录）")
+	fs.StringVar(&opts.dedupMode, "dedup", processor.DedupByFileName.String(), "去重方式：name（文件名）、sha256（内容哈希）或 invoice（发票号码）")
+	fs.StringVar(&opts.transferMode, "transfer", processor.TransferCopy.String(), "传输方式：copy（复制）、move（移动）、hardlink（硬链接）或 rename（在原目录重命名，可与输入目录相同）；ZIP / 邮件中的 PDF 总是写入新文件")
+	fs.DurationVar(&opts.fileTimeout, "file-timeout", 0, "单个 PDF 的处理超时，例如 30s（0 表示不限制）")
+	fs.IntVar(&opts.jobs, "jobs", runtime.NumCPU(), "并行提取 PDF 的工作协程数（1 表示串行）")
+	fs.BoolVar(&opts.jsonMode, "json", false, "以 JSON 输出汇总与逐文件结果")
+	fs.BoolVar(&opts.incremental, "incremental", false, "增量处理：跳过输出目录处理记录中已写入过的来源（记录保存在 "+processor.StateFileName+"）")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "仅生成处理计划，不写入输出目录")
+	fs.StringVar(&opts.savePlan, "save-plan", "", "将处理计划保存为 JSON 文件（隐含 -dry-run）")
+	fs.StringVar(&opts.applyPlan, "apply-plan", "", "按已保存的计划文件执行写入")
+	fs.StringVar(&opts.undoRun, "undo", "", "撤销指定运行写入的文件（运行 ID，或 latest 表示最近一次未撤销的运行；需配合 -output）")
+	fs.BoolVar(&opts.listRuns, "list-runs", false, "列出输出目录中的运行记录（需配合 -output）")
+	fs.BoolVar(&opts.watch, "watch", false, "持续监视输入目录，新的或变化的文件写入完成后立即处理（Ctrl+C 停止）")
+	fs.DurationVar(&opts.watchInterval, "watch-interval", processor.DefaultWatchInterval, "监视模式下检查输入目录的间隔")
+	fs.StringVar(&opts.reportPath, "report", "", "生成报销报表（.csv 或 .xlsx）；不指定 -input 时直接汇总 -output 目录中已有的 PDF")
+	fs.StringVar(&opts.vatPeriod, "vat-period", vat.PeriodMonth.String(), "报表中进项税抵扣的汇总期间：month（按月）或 quarter（按季度）")
+	fs.BoolVar(&opts.rejectTextPDF, "reject-text-fallback", false, "没有 XBRL、仅能从 PDF 文本层识别的发票计为失败（默认接受并标注“文本层识别”）")
+	fs.Var(&opts.pdfPasswords, "pdf-password", "加密 PDF 的打开密码（可重复指定多个，依次尝试；空密码总会先尝试）")
+
+	if err := fs.Parse(args); err != nil {
+		return runOptions{}, err
+	}
+	if fs.NArg() > 0 {
+		return runOptions{}, fmt.Errorf("多余的参数: %s", strings.Join(fs.Args(), " "))
+	}
+	if opts.applyPlan != "" && (opts.dryRun || opts.savePlan != "") {
+		return runOptions{}, errors.New("-apply-plan 不能与 -dry-run / -save-plan 同时使用")
+	}
+	if opts.reportPath != "" {
+		if err := report.ValidatePath(opts.reportPath); err != nil {
+			return runOptions{}, err
+		}
+		if opts.applyPlan != "" || opts.dryRun || opts.savePlan != "" || opts.undoRun != "" || opts.listRuns {
+			return runOptions{}, errors.New("-report 不能与 -apply-plan / -dry-run / -save-plan / -undo / -list-runs 同时使用")
+		}
+		if strings.TrimSpace(opts.outputDir) == "" {
+			return runOptions{}, errors.New("-report 需要指定 -output")
+		}
+	}
+	if strings.EqualFold(strings.TrimSpace(opts.transferMode), processor.TransferRenameInPlace.String()) && strings.TrimSpace(opts.dirTemplate) != "" {
+		return runOptions{}, errors.New("-transfer rename 不能与 -dir 同时使用（原地重命名的文件总是留在原目录）")
+	}
+	if opts.watch && (opts.applyPlan != "" || opts.dryRun || opts.savePlan != "" || opts.reportPath != "" || opts.undoRun != "" || opts.listRuns) {
+		return runOptions{}, errors.New("-watch 不能与 -apply-plan / -dry-run / -save-plan / -report / -undo / -list-runs 同时使用")
+	}
+	if opts.undoRun != "" || opts.listRuns {
+		if opts.undoRun != "" && opts.listRuns {
+			return runOptions{}, errors.New("-undo 不能与 -list-runs 同时使用")
+		}
+		if opts.applyPlan != "" || opts.dryRun || opts.savePlan != "" {
+			return runOptions{}, errors.New("-undo / -list-runs 不能与 -apply-plan / -dry-run / -save-plan 同时使用")
+		}
+		if strings.TrimSpace(opts.outputDir) == "" {
+			return runOptions{}, errors.New("-undo / -list-runs 需要指定 -output")
+		}
+	}
+	return opts, nil
+}
+
+func buildConfig(opts runOptions) (processor.Config, error) {
+	if strings.TrimSpace(opts.inputDir) == "" {
+		return processor.Config{}, errors.New("未指定 -input")
+	}
+	if strings.TrimSpace(opts.outputDir) == "" {
+		return processor.Config{}, errors.New("未指定 -output")
+	}
+	field, err := parseDateField(opts.dateField)
+	if err != nil {
+		return processor.Config{}, err
+	}
+	if err := processor.ValidateNameTemplate(opts.nameTemplate); err != nil {
+		return processor.Config{}, err
+	}
+	if err := processor.ValidateDirTemplate(opts.dirTemplate); err != nil {
+		return processor.Config{}, err
+	}
+	dedup, err := parseDedupMode(opts.dedupMode)
+	if err != nil {
+		return processor.Config{}, err
+	}
+	transfer, err := parseTransferMode(opts.transferMode)
+	if err != nil {
+		return processor.Config{}, err
+	}
+	return processor.Config{
+		InputDir:            opts.inputDir,
+		OutputDir:           opts.outputDir,
+		DateField:           field,
+		NameTemplate:        opts.nameTemplate,
+		DirTemplate:         opts.dirTemplate,
+		DedupMode:           dedup,
+		TransferMode:        transfer,
+		FileTimeout:         opts.fileTimeout,
+		Concurrency:         opts.jobs,
+		Incremental:         opts.incremental,
+		RejectLowConfidence: opts.rejectTextPDF,
+		PDFPasswords:        opts.pdfPasswords,
+	}, nil
+}
+
+func parseDateField(s string) (invoice.DateField, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case dateFlagTravel:
+		return invoice.DateFieldTravel, nil
+	case dateFlagIssue:
+		return invoice.DateFieldIssue, nil
+	default:
+		return 0, fmt.Errorf("未知日期字段: %q", s)
+	}
+}
+
+func parseDedupMode(s string) (processor.DedupMode, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	for _, m := range []processor.DedupMode{processor.DedupByFileName, processor.DedupBySHA256, processor.DedupByInvoiceNumber} {
+		if v == m.String() {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("未知去重方式: %q", s)
+}
+
+func parseTransferMode(s string) (processor.TransferMode, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	for _, m := range []processor.TransferMode{processor.TransferCopy, processor.TransferMove, processor.TransferHardLink, processor.TransferRenameInPlace} {
+		if v == m.String() {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("未知传输方式: %q", s)
+}
+
+func exitCode(sum processor.Summary, runErr error) int {
+	if errors.Is(runErr, processor.ErrCanceled) {
+		return exitCanceled
+	}
+	if runErr != nil {
+		return exitFatal
+	}
+	if sum.Failed > 0 {
+		return exitPartialFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"TrainTicketsTool/internal/processor"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type runRecord struct {
+	RunID     string     `json:"runId"`
+	StartedAt time.Time  `json:"startedAt"`
+	Files     int        `json:"files"`
+	UndoneAt  *time.Time `json:"undoneAt,omitempty"`
+}
+
+func listRuns(out *output, outputDir string) int {
+	journals, err := processor.ListJournals(outputDir)
+	if err != nil {
+		fmt.Fprintln(out.errW, "读取运行记录失败:", err)
+		return exitFatal
+	}
+
+	records := make([]runRecord, 0, len(journals))
+	for _, j := range journals {
+		records = append(records, runRecord{RunID: j.RunID, StartedAt: j.StartedAt, Files: len(j.Entries), UndoneAt: j.UndoneAt})
+	}
+	if out.jsonMode {
+		enc := json.NewEncoder(out.w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(records); err != nil {
+			fmt.Fprintln(out.errW, "输出 JSON 失败:", err)
+			return exitFatal
+		}
+		return exitOK
+	}
+
+	if len(records) == 0 {
+		fmt.Fprintln(out.w, "没有运行记录")
+		return exitOK
+	}
+	for _, rec := range records {
+		line := fmt.Sprintf("%s  %s  %d 个文件", rec.RunID, rec.StartedAt.Format(time.DateTime), rec.Files)
+		if rec.UndoneAt != nil {
+			line += "  （已撤销）"
+		}
+		fmt.Fprintln(out.w, line)
+	}
+	return exitOK
+}
@@ -2,3 +2,4 @@ module TrainTicketsTool
 
 go 1.22
 
+require golang.org/x/sys v0.28.0
@@ -4,6 +4,7 @@ package gui
 
 import (
 	"TrainTicketsTool/internal/processor"
+	"context"
 	"syscall"
 	"unsafe"
 )
@@ -28,6 +29,8 @@ const (
 	idDateIssue    = 1006
 	idStartButton  = 1007
 	idLogEdit      = 1008
+	idStopButton   = 1009
+	idWatchCheck   = 1010
 )
 
 type app struct {
@@ -48,6 +51,8 @@ type app struct {
 	dateTravel   syscall.Handle
 	dateIssue    syscall.Handle
 	startButton  syscall.Handle
+	stopButton   syscall.Handle
+	watchCheck   syscall.Handle
 	logEdit      syscall.Handle
 
 	worker *worker
@@ -56,6 +61,7 @@ type app struct {
 type worker struct {
 	logCh  chan string
 	doneCh chan workerDone
+	cancel context.CancelFunc
 }
 
 type workerDone struct {
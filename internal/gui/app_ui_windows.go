@@ -4,6 +4,7 @@ package gui
 
 import (
 	"TrainTicketsTool/internal/processor"
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -24,6 +25,8 @@ const (
 	uiRadioW    int32 = 220
 	uiRadioGap  int32 = 240
 	uiStartBtnW int32 = 120
+	uiStopBtnW  int32 = 120
+	uiWatchW    int32 = 500
 	uiLogLabelY int32 = 4
 )
 
@@ -72,6 +75,9 @@ func (a *app) createDateRow(hwnd syscall.Handle, y int32) int32 {
 
 func (a *app) createStartRow(hwnd syscall.Handle, y int32) int32 {
 	a.startButton = createButton(hwnd, idStartButton, "开始处理", uiMargin, y, uiStartBtnW, uiRowH)
+	a.stopButton = createButton(hwnd, idStopButton, "停止", uiMargin+uiStartBtnW+uiGapSmall, y, uiStopBtnW, uiRowH)
+	enableWindow(a.stopButton, false)
+	a.watchCheck = createCheckBox(hwnd, idWatchCheck, "持续监视输入目录（新文件写入完成后自动处理，跳过已处理过的文件）", uiMargin+uiStartBtnW+uiGapSmall+uiStopBtnW+uiGapSmall*2, y, uiWatchW, uiRowH)
 	createStatic(hwnd, "日志：", uiMargin, y+uiRowH+uiRowGap+uiLogLabelY, uiLabelW, uiRowH)
 	return y + uiRowH + uiRowGap + uiRowH
 }
@@ -103,6 +109,8 @@ func (a *app) onCommand(hwnd syscall.Handle, wParam uintptr) {
 		}
 	case idStartButton:
 		a.startProcessing(hwnd)
+	case idStopButton:
+		a.stopProcessing()
 	default:
 		return
 	}
@@ -117,6 +125,8 @@ func (a *app) startProcessing(hwnd syscall.Handle) {
 	if err != nil {
 		return
 	}
+	watch := isChecked(a.watchCheck)
+	cfg.Incremental = watch
 	if err := a.ensureDirsForRun(hwnd, cfg); err != nil {
 		showErrorBox("目录错误", err.Error())
 		return
@@ -126,14 +136,24 @@ func (a *app) startProcessing(hwnd syscall.Handle) {
 	clearLog(a.logEdit)
 	disableControls(a, true)
 
+	ctx, cancel := context.WithCancel(context.Background())
 	w := &worker{
 		logCh:  make(chan string, logBufferSize),
 		doneCh: make(chan workerDone, 1),
+		cancel: cancel,
 	}
 	a.worker = w
 
 	setTimer(hwnd, timerID, uiPollIntervalMs)
-	go runWorker(cfg, w)
+	go runWorker(ctx, cfg, watch, w)
+}
+
+func (a *app) stopProcessing() {
+	if a.worker == nil {
+		return
+	}
+	a.worker.cancel()
+	enableWindow(a.stopButton, false)
 }
 
 func (a *app) onTimer(hwnd syscall.Handle) {
@@ -146,6 +166,7 @@ func (a *app) onTimer(hwnd syscall.Handle) {
 	case done := <-a.worker.doneCh:
 		killTimer(hwnd, timerID)
 		drainLog(a.logEdit, a.worker.logCh)
+		a.worker.cancel()
 		a.worker = nil
 		disableControls(a, false)
 		a.showDone(done)
@@ -155,6 +176,11 @@ func (a *app) onTimer(hwnd syscall.Handle) {
 }
 
 func (a *app) showDone(done workerDone) {
+	if errors.Is(done.err, processor.ErrCanceled) {
+		msg := fmt.Sprintf("已停止。\n发现 PDF：%d\n成功：%d\n失败：%d", done.sum.FoundPDF, done.sum.Succeeded, done.sum.Failed)
+		showInfoBox("处理已停止", msg)
+		return
+	}
 	if done.err != nil {
 		showErrorBox("处理失败", done.err.Error())
 		return
@@ -5,18 +5,28 @@ package gui
 import (
 	"TrainTicketsTool/internal/invoice"
 	"TrainTicketsTool/internal/processor"
+	"context"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 )
 
-func runWorker(cfg processor.Config, w *worker) {
+func runWorker(ctx context.Context, cfg processor.Config, watch bool, w *worker) {
 	start := time.Now()
-	sum, err := processor.Run(cfg, func(line string) {
+	onEvent := processor.LogLineHandler(func(line string) {
 		w.logCh <- line
 	})
+	var sum processor.Summary
+	var err error
+	if watch {
+		w.logCh <- fmt.Sprintf("监视模式已启用增量处理：跳过 %s 中记录的已处理文件", processor.StateFileName)
+		sum, err = processor.Watch(ctx, cfg, processor.DefaultWatchInterval, onEvent)
+	} else {
+		sum, err = processor.RunWithEvents(ctx, cfg, onEvent)
+	}
 	w.logCh <- fmt.Sprintf("用时：%s", time.Since(start).Round(time.Millisecond))
 	close(w.logCh)
 	w.doneCh <- workerDone{sum: sum, err: err}
@@ -49,9 +59,10 @@ func buildConfig(inputDir string, outputDir string, useTravelDate bool) (process
 	}
 
 	return processor.Config{
-		InputDir:  inAbs,
-		OutputDir: outAbs,
-		DateField: field,
+		InputDir:    inAbs,
+		OutputDir:   outAbs,
+		DateField:   field,
+		Concurrency: runtime.NumCPU(),
 	}, nil
 }
 
@@ -96,6 +96,11 @@ func createRadio(parent syscall.Handle, id int, text string, x, y, w, h int32, c
 	return hwnd
 }
 
+func createCheckBox(parent syscall.Handle, id int, text string, x, y, w, h int32) syscall.Handle {
+	style := uint32(wsChild | wsVisible | bsAutoCheckBox)
+	return createControl("BUTTON", text, style, x, y, w, h, parent, id)
+}
+
 func createLogEdit(parent syscall.Handle, id int, x, y, w, h int32) syscall.Handle {
 	style := uint32(wsChild | wsVisible | esMultiLine | esAutoVScroll | esReadOnly | wsVScroll)
 	return createControl("EDIT", "", style, x, y, w, h, parent, id)
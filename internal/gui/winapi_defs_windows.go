@@ -139,6 +139,7 @@ const (
 	wsVScroll = 0x00200000
 
 	bsPushButton      = 0x00000000
+	bsAutoCheckBox    = 0x00000003
 	bsAutoRadioButton = 0x00000009
 
 	bmGetCheck = 0x00F0
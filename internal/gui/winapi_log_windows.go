@@ -47,7 +47,9 @@ func disableControls(a *app, disabled bool) {
 	enableWindow(a.outputBrowse, enable)
 	enableWindow(a.dateTravel, enable)
 	enableWindow(a.dateIssue, enable)
+	enableWindow(a.watchCheck, enable)
 	enableWindow(a.startButton, enable)
+	enableWindow(a.stopButton, disabled)
 }
 
 func setFixedWindowSize(hwnd syscall.Handle, w int32, h int32) {
@@ -0,0 +1,54 @@
+package invoice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const centsPerYuan = 100
+
+var amountNoise = strings.NewReplacer("¥", "", "￥", "", "元", "", ",", "", "，", "", " ", "")
+
+func ParseAmountCents(s string) (int64, bool) {
+	v := amountNoise.Replace(strings.TrimSpace(s))
+	if v == "" {
+		return 0, false
+	}
+	neg := strings.HasPrefix(v, "-")
+	v = strings.TrimPrefix(v, "-")
+
+	whole, frac, _ := strings.Cut(v, ".")
+	if whole == "" && frac == "" {
+		return 0, false
+	}
+	if len(frac) > 2 {
+		return 0, false
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	y, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	c, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	cents := y*centsPerYuan + c
+	if neg {
+		cents = -cents
+	}
+	return cents, true
+}
+
+func FormatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/centsPerYuan, cents%centsPerYuan)
+}
@@ -0,0 +1,176 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+)
+
+const (
+	pdfHeaderPrefix    = "%PDF-"
+	pdfHeaderSearchLen = 1024
+
+	pdfNameVersion pdfName = "Version"
+
+	XbrlSourcePlain    = "plain"
+	XbrlSourceEmbedded = "EmbeddedFile"
+
+	inspectStepUnlock   = "解密"
+	inspectStepPlain    = "明文 XBRL"
+	inspectStepEmbedded = "EmbeddedFile"
+	inspectStepParse    = "解析 XBRL"
+	inspectStepInvoice  = "发票字段"
+	inspectStepText     = "文本层"
+)
+
+var errNoPlainXbrl = errors.New("PDF 中没有未压缩的 XBRL")
+
+type Inspection struct {
+	Version       string             `json:"version,omitempty"`
+	Encrypted     bool               `json:"encrypted,omitempty"`
+	EmbeddedFiles []EmbeddedFileInfo `json:"embeddedFiles"`
+	XbrlSource    string             `json:"xbrlSource,omitempty"`
+	XbrlObject    int                `json:"xbrlObject,omitempty"`
+	Xbrl          string             `json:"xbrl,omitempty"`
+	Facts         []XbrlFact         `json:"facts,omitempty"`
+	Steps         []InspectStep      `json:"steps"`
+	Info          *InvoiceInfo       `json:"info,omitempty"`
+}
+
+type EmbeddedFileInfo struct {
+	Name    string   `json:"name,omitempty"`
+	Object  int      `json:"object,omitempty"`
+	Filters []string `json:"filters,omitempty"`
+	Length  int      `json:"length"`
+	Size    int      `json:"size"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type InspectStep struct {
+	Step   string `json:"step"`
+	Object int    `json:"object,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func InspectPDF(ctx context.Context, pdfBytes []byte, passwords []string) Inspection {
+	in := Inspection{Version: pdfHeaderVersion(pdfBytes), EmbeddedFiles: []EmbeddedFileInfo{}, Steps: []InspectStep{}}
+	doc := openPDFDocument(ctx, pdfBytes)
+	_, in.Encrypted = doc.trailer()[pdfNameEncrypt]
+	unlockErr := doc.unlock(passwords)
+	if in.Encrypted {
+		in.addStep(InspectStep{Step: inspectStepUnlock}, unlockErr)
+	}
+	if unlockErr == nil {
+		if catalog, ok := doc.resolveDict(doc.trailer()[pdfNameRoot]); ok {
+			if v, ok := catalog[pdfNameVersion].(pdfName); ok && string(v) > in.Version {
+				in.Version = string(v)
+			}
+		}
+		in.EmbeddedFiles = append(in.EmbeddedFiles, doc.embeddedFileInfos(ctx)...)
+	}
+
+	xbrl, ok := extractPlainXbrl(pdfBytes)
+	if ok {
+		in.XbrlSource = XbrlSourcePlain
+		in.addStep(InspectStep{Step: inspectStepPlain}, nil)
+	} else {
+		in.addStep(InspectStep{Step: inspectStepPlain}, errNoPlainXbrl)
+	}
+	if !ok && unlockErr == nil {
+		attempts := 0
+		embedded, f, err := doc.findEmbeddedXbrl(ctx, func(f embeddedFile, err error) {
+			attempts++
+			in.addStep(InspectStep{Step: inspectStepEmbedded, Object: f.ref.objNum, Name: f.name}, err)
+		})
+		switch {
+		case err == nil:
+			xbrl, ok = embedded, true
+			in.XbrlSource, in.XbrlObject = XbrlSourceEmbedded, f.ref.objNum
+		case attempts == 0:
+			in.addStep(InspectStep{Step: inspectStepEmbedded}, err)
+		}
+	}
+
+	if !ok {
+		if unlockErr == nil {
+			info, err := extractInvoiceInfoFromTextLayer(ctx, pdfBytes, passwords)
+			in.addStep(InspectStep{Step: inspectStepText}, err)
+			if err == nil {
+				in.Info = &info
+			}
+		}
+		return in
+	}
+
+	in.Xbrl = string(xbrl)
+	parsed, err := ParseXbrlDocument(xbrl)
+	in.addStep(InspectStep{Step: inspectStepParse}, err)
+	if err != nil {
+		return in
+	}
+	in.Facts = parsed.Facts
+	info, err := parsed.InvoiceInfo()
+	in.addStep(InspectStep{Step: inspectStepInvoice}, err)
+	if err == nil {
+		in.Info = &info
+	}
+	return in
+}
+
+func (in *Inspection) addStep(step InspectStep, err error) {
+	if err != nil {
+		step.Error = err.Error()
+	}
+	in.Steps = append(in.Steps, step)
+}
+
+func (d *pdfDocument) embeddedFileInfos(ctx context.Context) []EmbeddedFileInfo {
+	seen := make(map[int]bool)
+	var out []EmbeddedFileInfo
+	for _, files := range [][]embeddedFile{d.catalogEmbeddedFiles(ctx), d.scannedEmbeddedFiles(ctx)} {
+		for _, f := range files {
+			if f.ref.objNum > 0 {
+				if seen[f.ref.objNum] {
+					continue
+				}
+				seen[f.ref.objNum] = true
+			}
+			out = append(out, d.embeddedFileInfo(f))
+		}
+	}
+	sort.SliceStable(out, func(a, b int) bool { return out[a].Object < out[b].Object })
+	return out
+}
+
+func (d *pdfDocument) embeddedFileInfo(f embeddedFile) EmbeddedFileInfo {
+	info := EmbeddedFileInfo{Name: f.name, Object: f.ref.objNum, Length: len(f.stream.raw)}
+	filters, _, err := d.streamFilters(f.stream.dict)
+	for _, name := range filters {
+		info.Filters = append(info.Filters, string(name))
+	}
+	if err == nil {
+		var data []byte
+		data, err = d.decodeStream(f.stream)
+		info.Size = len(data)
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	return info
+}
+
+func pdfHeaderVersion(pdfBytes []byte) string {
+	head := pdfBytes[:min(len(pdfBytes), pdfHeaderSearchLen)]
+	i := bytes.Index(head, []byte(pdfHeaderPrefix))
+	if i < 0 {
+		return ""
+	}
+	rest := head[i+len(pdfHeaderPrefix):]
+	n := 0
+	for n < len(rest) && (rest[n] == '.' || (rest[n] >= '0' && rest[n] <= '9')) {
+		n++
+	}
+	return string(rest[:n])
+}
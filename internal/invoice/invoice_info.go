@@ -1,9 +1,29 @@
 package invoice
 
 type InvoiceInfo struct {
-	TravelDate         string
-	DateOfIssue        string
-	DepartureStation   string
-	DestinationStation string
-}
+	TravelDate         string `json:"travelDate,omitempty"`
+	DateOfIssue        string `json:"dateOfIssue,omitempty"`
+	DepartureStation   string `json:"departureStation,omitempty"`
+	DestinationStation string `json:"destinationStation,omitempty"`
+
+	InvoiceNumber          string `json:"invoiceNumber,omitempty"`
+	ElectronicTicketNumber string `json:"electronicTicketNumber,omitempty"`
+	TrainNumber            string `json:"trainNumber,omitempty"`
+	SeatClass              string `json:"seatClass,omitempty"`
+	Carriage               string `json:"carriage,omitempty"`
+	SeatNumber             string `json:"seatNumber,omitempty"`
+	DepartureTime          string `json:"departureTime,omitempty"`
+	PassengerName          string `json:"passengerName,omitempty"`
+	PassengerIDMasked      string `json:"passengerIdMasked,omitempty"`
+	Fare                   string `json:"fare,omitempty"`
+	TaxRate                string `json:"taxRate,omitempty"`
+	TaxAmount              string `json:"taxAmount,omitempty"`
+	BuyerName              string `json:"buyerName,omitempty"`
+	BuyerTaxID             string `json:"buyerTaxId,omitempty"`
 
+	ItemName              string `json:"itemName,omitempty"`
+	Remarks               string `json:"remarks,omitempty"`
+	OriginalInvoiceNumber string `json:"originalInvoiceNumber,omitempty"`
+
+	LowConfidence bool `json:"lowConfidence,omitempty"`
+}
@@ -1,9 +1,21 @@
 package invoice
 
 import (
+	"archive/zip"
 	"bytes"
+	"compress/lzw"
 	"compress/zlib"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -61,6 +73,788 @@ func TestExtractInvoiceInfoFromPDFBytes_EmbeddedFlateXbrl_IgnoresOtherLength(t *
 	assertInfo(t, info)
 }
 
+func TestExtractInvoiceInfoFromPDFBytes_HugeStreamLength(t *testing.T) {
+	comp := compressZlib([]byte(testXbrlXML))
+
+	pdf := bytes.Join([][]byte{
+		[]byte("%PDF-1.7\r\n"),
+		[]byte("44 0 obj\r\n<</Filter/FlateDecode/Length 9223372036854775807/Type/EmbeddedFile>>stream\r\n"),
+		comp,
+		[]byte("\r\nendstream\r\nendobj\r\n%%EOF\r\n"),
+	}, nil)
+
+	info, err := ExtractInvoiceInfoFromPDFBytes(pdf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertInfo(t, info)
+}
+
+func TestExtractInvoiceInfoFromPDFBytes_IncrementalUpdateUsesLatestRevision(t *testing.T) {
+	stale := strings.Replace(testXbrlXML, "三门峡南", "洛阳龙门", 1)
+	comp := compressZlib([]byte(stale))
+
+	var b pdfTestBuilder
+	b.header()
+	b.object(1, "<</Type/Catalog/Names 2 0 R>>")
+	b.object(2, "<</EmbeddedFiles 3 0 R>>")
+	b.object(3, "<</Names[(invoice.xbrl) 4 0 R]>>")
+	b.object(4, "<</Type/Filespec/F(invoice.xbrl)/EF<</F 5 0 R>>>>")
+	b.stream(5, "/Type/EmbeddedFile/Filter/FlateDecode/Length 6 0 R", comp)
+	b.object(6, strconv.Itoa(len(comp)))
+	firstXref := b.xref("/Size 7/Root 1 0 R")
+
+	fresh := compressZlib([]byte(testXbrlXML))
+	b.stream(5, "/Type/EmbeddedFile/Filter/FlateDecode/Length 6 0 R", fresh)
+	b.object(6, strconv.Itoa(len(fresh)))
+	b.xref("/Size 7/Root 1 0 R/Prev " + strconv.Itoa(firstXref))
+
+	if doc := openPDFDocument(context.Background(), b.buf.Bytes()); doc.xref == nil || len(doc.xref.entries) != 7 {
+		t.Fatalf("xref chain not loaded: %+v", doc.xref)
+	}
+	info, err := ExtractInvoiceInfoFromPDFBytes(b.buf.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertInfo(t, info)
+}
+
+func TestExtractInvoiceInfoFromPDFBytes_ObjectAndXrefStreams(t *testing.T) {
+	comp := compressZlib([]byte(testXbrlXML))
+	inStream := []struct {
+		num  int
+		body string
+	}{
+		{1, "<</Type/Catalog/Names 2 0 R>>"},
+		{2, "<</EmbeddedFiles 3 0 R>>"},
+		{3, "<</Names[(invoice.xbrl) 4 0 R]>>"},
+		{4, "<</Type/Filespec/F(invoice.xbrl)/EF<</F 5 0 R>>>>"},
+		{6, strconv.Itoa(len(comp))},
+	}
+	var header, body bytes.Buffer
+	for _, o := range inStream {
+		fmt.Fprintf(&header, "%d %d ", o.num, body.Len())
+		body.WriteString(o.body + "\n")
+	}
+	objStm := compressZlib(append(header.Bytes(), body.Bytes()...))
+
+	var b pdfTestBuilder
+	b.header()
+	b.stream(5, "/Type/EmbeddedFile/Filter/FlateDecode/Length 6 0 R", comp)
+	b.stream(7, fmt.Sprintf("/Type/ObjStm/N %d/First %d/Filter/FlateDecode/Length %d", len(inStream), header.Len(), len(objStm)), objStm)
+
+	rows := [][]byte{{0, 0, 0, 0}}
+	for num := 1; num <= 8; num++ {
+		switch {
+		case num == 5 || num == 7:
+			off := b.offsets[num]
+			rows = append(rows, []byte{1, byte(off >> 8), byte(off), 0})
+		case num == 8:
+			off := b.buf.Len()
+			rows = append(rows, []byte{1, byte(off >> 8), byte(off), 0})
+		default:
+			idx := 0
+			for i, o := range inStream {
+				if o.num == num {
+					idx = i
+				}
+			}
+			rows = append(rows, []byte{2, 0, 7, byte(idx)})
+		}
+	}
+	var predicted []byte
+	prev := make([]byte, 4)
+	for _, row := range rows {
+		predicted = append(predicted, 2)
+		for i := range row {
+			predicted = append(predicted, row[i]-prev[i])
+		}
+		prev = row
+	}
+	xrefData := compressZlib(predicted)
+	xrefOffset := b.buf.Len()
+	b.stream(8, fmt.Sprintf("/Type/XRef/Size 9/W[1 2 1]/Root 1 0 R/Filter/FlateDecode/DecodeParms<</Predictor 12/Columns 4>>/Length %d", len(xrefData)), xrefData)
+	fmt.Fprintf(&b.buf, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	doc := openPDFDocument(context.Background(), b.buf.Bytes())
+	if doc.xref == nil || doc.xref.entries[6].kind != xrefCompressed {
+		t.Fatalf("xref stream not loaded: %+v", doc.xref)
+	}
+	info, err := ExtractInvoiceInfoFromPDFBytes(b.buf.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertInfo(t, info)
+}
+
+func TestExtractInvoiceInfoFromPDFBytes_MalformedObjectStream(t *testing.T) {
+	comp := compressZlib([]byte(testXbrlXML))
+	for _, header := range []string{"1 -20 ", "-1 0 "} {
+		data := header + "<</Type/Catalog>>\n"
+		var b pdfTestBuilder
+		b.header()
+		b.stream(7, fmt.Sprintf("/Type/ObjStm/N 1/First %d/Length %d", len(header), len(data)), []byte(data))
+		b.stream(5, fmt.Sprintf("/Type/EmbeddedFile/Filter/FlateDecode/Length %d", len(comp)), comp)
+		b.xref("/Size 8/Root 1 0 R")
+
+		info, err := ExtractInvoiceInfoFromPDFBytes(b.buf.Bytes())
+		if err != nil {
+			t.Fatalf("header %q: unexpected error: %v", header, err)
+		}
+		assertInfo(t, info)
+		doc := openPDFDocument(context.Background(), b.buf.Bytes())
+		if _, err := doc.objectStream(7); err == nil {
+			t.Fatalf("expected error for object stream header %q", header)
+		}
+	}
+}
+
+func TestExtractInvoiceInfoFromPDFBytes_FilterChains(t *testing.T) {
+	pngUp := func(data []byte, columns int) []byte {
+		var out []byte
+		prev := make([]byte, columns)
+		for i := 0; i < len(data); i += columns {
+			row := make([]byte, columns)
+			copy(row, data[i:])
+			out = append(out, 2)
+			for j := range row {
+				out = append(out, row[j]-prev[j])
+			}
+			prev = row
+		}
+		return out
+	}
+	ascii85Encode := func(data []byte) []byte {
+		out := make([]byte, ascii85.MaxEncodedLen(len(data)))
+		n := ascii85.Encode(out, data)
+		return append(append([]byte("<~"), out[:n]...), "~>"...)
+	}
+	lzwEncode := func(data []byte) []byte {
+		var buf bytes.Buffer
+		w := lzw.NewWriter(&buf, lzw.MSB, 8)
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+	runLengthEncode := func(data []byte) []byte {
+		var out []byte
+		for len(data) > 0 {
+			if len(data) > 1 && data[0] == data[1] {
+				out = append(out, 255, data[0])
+				data = data[2:]
+				continue
+			}
+			n := min(len(data), 3)
+			out = append(append(out, byte(n-1)), data[:n]...)
+			data = data[n:]
+		}
+		return append(out, 128)
+	}
+	payload := []byte(strings.Repeat(testXbrlXML, 40))
+
+	cases := []struct {
+		name string
+		dict string
+		data []byte
+	}{
+		{
+			name: "ascii85+flate+predictor",
+			dict: "/Filter[/ASCII85Decode/FlateDecode]/DecodeParms[null<</Predictor 12/Columns 8>>]",
+			data: ascii85Encode(compressZlib(pngUp(payload, 8))),
+		},
+		{
+			name: "asciihex+lzw",
+			dict: "/Filter[/AHx/LZW]/DecodeParms[null<</EarlyChange 0>>]",
+			data: []byte(strings.ToUpper(hex.EncodeToString(lzwEncode(payload))) + ">"),
+		},
+		{
+			name: "runlength",
+			dict: "/Filter/RunLengthDecode",
+			data: runLengthEncode([]byte(testXbrlXML)),
+		},
+	}
+	for _, tc := range cases {
+		var b pdfTestBuilder
+		b.header()
+		b.stream(5, fmt.Sprintf("/Type/EmbeddedFile%s/Length %d", tc.dict, len(tc.data)), tc.data)
+		info, err := ExtractInvoiceInfoFromPDFBytes(b.buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		assertInfo(t, info)
+	}
+}
+
+func TestExtractInvoiceInfoFromPDFBytes_Encrypted(t *testing.T) {
+	cases := []struct {
+		name      string
+		enc       testEncryption
+		passwords []string
+		wantErr   error
+	}{
+		{name: "rc4-128 empty user password", enc: testEncryption{v: 2, r: 3, keyLen: 16, owner: "owner"}},
+		{name: "rc4-40 r2", enc: testEncryption{v: 1, r: 2, keyLen: 5, owner: "owner"}},
+		{name: "aesv2 user password", enc: testEncryption{v: 4, r: 4, keyLen: 16, cfm: "AESV2", user: "1234", owner: "owner"}, passwords: []string{"wrong", "1234"}},
+		{name: "aesv2 owner password", enc: testEncryption{v: 4, r: 4, keyLen: 16, cfm: "AESV2", user: "1234", owner: "owner"}, passwords: []string{"owner"}},
+		{name: "aesv2 missing password", enc: testEncryption{v: 4, r: 4, keyLen: 16, cfm: "AESV2", user: "1234", owner: "owner"}, wantErr: ErrPDFPasswordRequired},
+		{name: "aesv3 empty user password", enc: testEncryption{v: 5, r: 6, keyLen: 32, cfm: "AESV3", owner: "owner"}},
+		{name: "aesv3 user password", enc: testEncryption{v: 5, r: 6, keyLen: 32, cfm: "AESV3", user: "密码", owner: "owner"}, passwords: []string{"密码"}},
+		{name: "aesv3 owner password", enc: testEncryption{v: 5, r: 6, keyLen: 32, cfm: "AESV3", user: "1234", owner: "owner"}, passwords: []string{"owner"}},
+	}
+	for _, tc := range cases {
+		pdf := tc.enc.build(t, []byte(testXbrlXML))
+		info, err := ExtractInvoiceInfoFromPDFBytesWithPasswords(context.Background(), pdf, tc.passwords)
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("%s: want %v, got %v", tc.name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		assertInfo(t, info)
+	}
+}
+
+func TestSecurityHandler_PDFJSKnownAnswerVectors(t *testing.T) {
+	unhex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatalf("bad hex %q: %v", s, err)
+		}
+		return b
+	}
+	r3 := &securityHandler{v: 2, r: 3, keyLen: 16, p: -1028, encryptMetadata: true,
+		id0: unhex("f6c6af17f372528d524d9a80d1efdf18"),
+		o:   unhex("80c30496916f20736c3ae61b135491f20d5612e3ff5ebbe9564fd86b9aca7c5d"),
+		u:   unhex("6a0c8d3e591900bc6a647d91bdaa001800000000000000000000000000000000")}
+	r4 := &securityHandler{v: 4, r: 4, keyLen: 16, p: -1084, encryptMetadata: true,
+		id0: unhex("3c4c5f3a4496af409a9db33c781c76ac"),
+		o:   unhex("734614762e793527db970a3522b3e1d4adbd9b3cb4a5897515b259f168d9e9f4"),
+		u:   unhex("930489a9bf8a45a688a2dbc2a0a8676e00000000000000000000000000000000")}
+	r5 := &securityHandler{v: 5, r: 5, keyLen: 32,
+		u:  unhex("83f28fa057028a864ffdbdade04990f1be51c50ff96991970fc24103017ebbdd75a904209f6516dca85ed7c06426bc28"),
+		ue: unhex("2396c3a9f53333ff9e9e21f2e74b7dbe197eac72c3f489f5eaa52a4a3c261111"),
+		o:  unhex("3c6289233365c898d2b2e2e486cda318cc7eb1246a32247dd2acab78de6c8b73f37647998011653e0000000000000000"),
+		oe: make([]byte, 32)}
+	r6 := &securityHandler{v: 5, r: 6, keyLen: 32,
+		u:  unhex("5ee6cd4ba663fa4cdb801155391121a4962e67b0a09cbbe9a6dfa3fd93eb5fb853f59265c6f722c6bf0b105eedd814af"),
+		ue: unhex("79d002b5e6599c3cfd8fd41c54b4c4b1ad80dd6b2e145eba87335f1814dffe24"),
+		o:  unhex("58e83e36f51af5d1897bdd48c73125d91f4a73a77f9eb04d2da3572f275ad98d8ee8a9d0cad605b90000000000000000"),
+		oe: make([]byte, 32)}
+
+	cases := []struct {
+		name     string
+		h        *securityHandler
+		password string
+		wantOK   bool
+		wantKey  string
+	}{
+		{name: "r3 user", h: r3, password: "123456", wantOK: true},
+		{name: "r3 owner", h: r3, password: "654321", wantOK: true},
+		{name: "r3 wrong", h: r3, password: "wrong"},
+		{name: "r4 empty user", h: r4, password: "", wantOK: true},
+		{name: "r4 wrong", h: r4, password: "123456"},
+		{name: "r5 user", h: r5, password: "user", wantOK: true, wantKey: "3f7288d1573d0c1ef901ba90fef8a3999733850a5098ce0f48bbe721e0ef0dd5"},
+		{name: "r5 owner", h: r5, password: "owner", wantOK: true},
+		{name: "r5 wrong", h: r5, password: "wrong"},
+		{name: "r6 user", h: r6, password: "user", wantOK: true, wantKey: "2adad527495b484f4326f88512bd3d226b4f1d383bb5d576712241d257ae16ef"},
+		{name: "r6 owner", h: r6, password: "owner", wantOK: true},
+		{name: "r6 wrong", h: r6, password: "wrong"},
+	}
+	for _, tc := range cases {
+		key, ok := tc.h.authenticate([]byte(tc.password))
+		if ok != tc.wantOK {
+			t.Fatalf("%s: authenticate=%v, want %v", tc.name, ok, tc.wantOK)
+		}
+		if tc.wantKey != "" && hex.EncodeToString(key) != tc.wantKey {
+			t.Fatalf("%s: file key %x, want %s", tc.name, key, tc.wantKey)
+		}
+	}
+}
+
+func TestExtractInvoiceInfoFromOFDBytes(t *testing.T) {
+	base := map[string]string{
+		"OFD.xml": `<?xml version="1.0" encoding="UTF-8"?><ofd:OFD xmlns:ofd="http://www.ofdspec.org/2016" Version="1.1"><ofd:DocBody>` +
+			`<ofd:DocInfo><ofd:CustomDatas><ofd:CustomData Name="发票号码">26110000000000000001</ofd:CustomData><ofd:CustomData Name="开票日期">2026-02-28</ofd:CustomData></ofd:CustomDatas></ofd:DocInfo>` +
+			`<ofd:DocRoot>Doc_0/Document.xml</ofd:DocRoot></ofd:DocBody></ofd:OFD>`,
+		"Doc_0/Pages/Page_0/Content.xml": `<ofd:Page xmlns:ofd="http://www.ofdspec.org/2016"><ofd:Content><ofd:Layer ID="2">` +
+			`<ofd:TextObject ID="10"><ofd:TextCode X="0" Y="0">三门峡</ofd:TextCode></ofd:TextObject>` +
+			`<ofd:TextObject ID="11"><ofd:TextCode X="0" Y="0">南</ofd:TextCode></ofd:TextObject>` +
+			`<ofd:TextObject ID="12"><ofd:TextCode X="0" Y="0">2026-02-11</ofd:TextCode></ofd:TextObject>` +
+			`</ofd:Layer></ofd:Content></ofd:Page>`,
+	}
+	withTags := map[string]string{
+		"Doc_0/Document.xml": `<ofd:Document xmlns:ofd="http://www.ofdspec.org/2016"><ofd:Pages><ofd:Page ID="1" BaseLoc="Pages/Page_0/Content.xml"/></ofd:Pages>` +
+			`<ofd:CustomTags>Tags/CustomTags.xml</ofd:CustomTags></ofd:Document>`,
+		"Doc_0/Tags/CustomTags.xml": `<ofd:CustomTags xmlns:ofd="http://www.ofdspec.org/2016"><ofd:CustomTag NameSpace="urn:rai"><ofd:FileLoc>CustomTag.xml</ofd:FileLoc></ofd:CustomTag></ofd:CustomTags>`,
+		"Doc_0/Tags/CustomTag.xml": `<rai:Invoice xmlns:rai="urn:rai" xmlns:ofd="http://www.ofdspec.org/2016">` +
+			`<rai:DepartureStation><ofd:ObjectRef PageRef="1">10</ofd:ObjectRef><ofd:ObjectRef PageRef="1">11</ofd:ObjectRef></rai:DepartureStation>` +
+			`<rai:DestinationStation>郑州</rai:DestinationStation>` +
+			`<rai:TravelDate><ofd:ObjectRef PageRef="1">12</ofd:ObjectRef></rai:TravelDate></rai:Invoice>`,
+	}
+	withAttachment := map[string]string{
+		"Doc_0/Document.xml":             `<ofd:Document xmlns:ofd="http://www.ofdspec.org/2016"><ofd:Attachments>Attachs/Attachments.xml</ofd:Attachments></ofd:Document>`,
+		"Doc_0/Attachs/Attachments.xml":  `<ofd:Attachments xmlns:ofd="http://www.ofdspec.org/2016"><ofd:Attachment ID="1" Name="invoice.xbrl"><ofd:FileLoc>/Doc_0/Attachs/invoice.xbrl</ofd:FileLoc></ofd:Attachment></ofd:Attachments>`,
+		"Doc_0/Attachs/invoice.xbrl":     testXbrlXML,
+		"Doc_0/Pages/Page_0/Content.xml": base["Doc_0/Pages/Page_0/Content.xml"],
+	}
+
+	for name, extra := range map[string]map[string]string{"custom tags": withTags, "xbrl attachment": withAttachment} {
+		files := make(map[string]string)
+		for k, v := range base {
+			files[k] = v
+		}
+		for k, v := range extra {
+			files[k] = v
+		}
+		info, err := ExtractInvoiceInfo(context.Background(), "ticket.OFD", buildZip(t, files), nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		assertInfo(t, info)
+		if info.InvoiceNumber != "26110000000000000001" {
+			t.Fatalf("%s: InvoiceNumber from CustomData = %q", name, info.InvoiceNumber)
+		}
+	}
+
+	nsXbrl := `<xbrl xmlns="http://www.xbrl.org/2003/instance" xmlns:rai="urn:rai">` +
+		`<rai:TravelDate contextRef="c1">2026-02-11</rai:TravelDate><rai:DepartureStation contextRef="c1">三门峡南</rai:DepartureStation>` +
+		`<rai:DestinationStation contextRef="c1">郑州</rai:DestinationStation><rai:SeatLevel contextRef="c1">二等座</rai:SeatLevel><rai:SeatType contextRef="c1">二等座</rai:SeatType></xbrl>`
+	conflicting := strings.Replace(nsXbrl, "</xbrl>", `<rai:DestinationStation contextRef="c1">洛阳龙门</rai:DestinationStation></xbrl>`, 1)
+	for xbrl, wantConflict := range map[string]bool{nsXbrl: false, conflicting: true} {
+		files := make(map[string]string)
+		for k, v := range withAttachment {
+			files[k] = v
+		}
+		files["OFD.xml"] = base["OFD.xml"]
+		files["Doc_0/Attachs/invoice.xbrl"] = xbrl
+		ofdInfo, ofdErr := ExtractInvoiceInfo(context.Background(), "ticket.ofd", buildZip(t, files), nil)
+		pdfInfo, pdfErr := ExtractInvoiceInfo(context.Background(), "ticket.pdf", []byte("%PDF-1.7\nstream\n"+xbrl+"\nendstream\n%%EOF\n"), nil)
+		if errors.Is(ofdErr, ErrConflictingXbrlFacts) != wantConflict || errors.Is(pdfErr, ErrConflictingXbrlFacts) != wantConflict {
+			t.Fatalf("OFD err=%v, PDF err=%v", ofdErr, pdfErr)
+		}
+		if pdfErr == nil && (ofdInfo.SeatClass != "二等座" || ofdInfo.SeatClass != pdfInfo.SeatClass) {
+			t.Fatalf("OFD info=%+v, PDF info=%+v", ofdInfo, pdfInfo)
+		}
+	}
+
+	_, err := ExtractInvoiceInfoFromOFDBytes(context.Background(), buildZip(t, map[string]string{"OFD.xml": base["OFD.xml"]}))
+	if err == nil || !strings.Contains(err.Error(), "Doc_0/Document.xml") {
+		t.Fatalf("expected missing Document.xml error, got %v", err)
+	}
+}
+
+func TestExtractInvoiceInfoFromPDFBytes_TextLayerFallback(t *testing.T) {
+	codes := map[rune]int{}
+	var cmap strings.Builder
+	cmap.WriteString("/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n")
+	cmap.WriteString("1 beginbfrange <0100> <0109> <0030> endbfrange\n")
+	encode := func(text string) string {
+		var sb strings.Builder
+		sb.WriteByte('<')
+		for _, r := range text {
+			code := 0
+			if r >= '0' && r <= '9' {
+				code = 0x100 + int(r-'0')
+			} else {
+				if _, ok := codes[r]; !ok {
+					codes[r] = len(codes) + 1
+					fmt.Fprintf(&cmap, "1 beginbfchar <%04X> <%04X> endbfchar\n", codes[r], r)
+				}
+				code = codes[r]
+			}
+			fmt.Fprintf(&sb, "%04X", code)
+		}
+		sb.WriteByte('>')
+		return sb.String()
+	}
+
+	content := "BT /F2 10 Tf 1 0 0 1 50 750 Tm " + encode("发票号码:26110000000000000001") + " Tj 200 0 Td " + encode("开票日期:2026年02月28日") + " Tj\n" +
+		"-200 -50 Td [" + encode("三门峡") + " -20 " + encode("南站") + "] TJ /F1 10 Tf 80 0 Td (G1234) Tj /F2 10 Tf 80 0 Td " + encode("郑州站") + " Tj ET\n" +
+		"q /X1 Do Q\n" +
+		"BT /F2 12 Tf 50 600 TD " + encode("￥88.00") + " Tj ET"
+	form := "BT /F2 10 Tf 1 0 0 1 50 700 Tm " + encode("2026年02月11日 08:15开") + " Tj 150 0 Td " + encode("05车12A号") + " Tj 80 0 Td " + encode("二等座") + " Tj ET"
+	cmap.WriteString("endcmap CMapName currentdict /CMap defineresource pop end end")
+
+	var b pdfTestBuilder
+	b.header()
+	b.object(1, "<</Type/Catalog/Pages 2 0 R>>")
+	b.object(2, "<</Type/Pages/Kids[3 0 R]/Count 1/Resources<</Font<</F1 4 0 R/F2 5 0 R>>/XObject<</X1 8 0 R>>>>>>")
+	b.object(3, "<</Type/Page/Parent 2 0 R/MediaBox[0 0 595 842]/Contents 7 0 R>>")
+	b.object(4, "<</Type/Font/Subtype/Type1/BaseFont/Helvetica>>")
+	b.object(5, "<</Type/Font/Subtype/Type0/BaseFont/SimSun/Encoding/Identity-H/ToUnicode 6 0 R>>")
+	b.stream(6, fmt.Sprintf("/Length %d", cmap.Len()), []byte(cmap.String()))
+	packed := compressZlib([]byte(content))
+	b.stream(7, fmt.Sprintf("/Filter/FlateDecode/Length %d", len(packed)), packed)
+	b.stream(8, fmt.Sprintf("/Type/XObject/Subtype/Form/BBox[0 0 595 842]/Matrix[1 0 0 1 0 -40]/Length %d", len(form)), []byte(form))
+	b.xref("/Size 9/Root 1 0 R")
+
+	info, err := ExtractInvoiceInfoFromPDFBytes(b.buf.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertInfo(t, info)
+	if !info.LowConfidence {
+		t.Fatalf("text layer result should be marked low confidence")
+	}
+	got := []string{info.InvoiceNumber, info.TrainNumber, info.DepartureTime, info.Carriage, info.SeatNumber, info.SeatClass, info.Fare}
+	want := []string{"26110000000000000001", "G1234", "08:15", "05", "12A", "二等座", "88.00"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("fields = %q, want %q", got, want)
+	}
+
+	var plain pdfTestBuilder
+	plain.header()
+	plain.object(1, "<</Type/Catalog>>")
+	plain.xref("/Size 2/Root 1 0 R")
+	if _, err := ExtractInvoiceInfoFromPDFBytes(plain.buf.Bytes()); err == nil || !strings.Contains(err.Error(), "文本层") {
+		t.Fatalf("expected combined XBRL/text layer error, got %v", err)
+	}
+}
+
+func TestInspectPDF_ReportsEmbeddedFilesAndSteps(t *testing.T) {
+	comp := compressZlib([]byte(testXbrlXML))
+
+	var b pdfTestBuilder
+	b.header()
+	b.object(1, "<</Type/Catalog/Version/1.7/Names<</EmbeddedFiles<</Names[(broken.xbrl) 3 0 R (invoice.xbrl) 5 0 R]>>>>>>")
+	b.object(3, "<</Type/Filespec/F(broken.xbrl)/EF<</F 4 0 R>>>>")
+	b.stream(4, "/Type/EmbeddedFile/Filter/FlateDecode/Length 9", []byte("not zlib!"))
+	b.object(5, "<</Type/Filespec/F(invoice.xbrl)/EF<</F 6 0 R>>>>")
+	b.stream(6, "/Type/EmbeddedFile/Filter/FlateDecode/Length "+strconv.Itoa(len(comp)), comp)
+	b.xref("/Size 7/Root 1 0 R")
+	pdf := bytes.Replace(b.buf.Bytes(), []byte("%PDF-1.7"), []byte("%PDF-1.4"), 1)
+
+	in := InspectPDF(context.Background(), pdf, nil)
+	if in.Version != "1.7" || in.XbrlSource != XbrlSourceEmbedded || in.XbrlObject != 6 || in.Xbrl != testXbrlXML {
+		t.Fatalf("inspection=%+v", in)
+	}
+	if len(in.EmbeddedFiles) != 2 || in.EmbeddedFiles[0].Name != "broken.xbrl" || in.EmbeddedFiles[0].Error == "" ||
+		in.EmbeddedFiles[1].Size != len(testXbrlXML) || in.EmbeddedFiles[1].Filters[0] != "FlateDecode" {
+		t.Fatalf("embedded files=%+v", in.EmbeddedFiles)
+	}
+	var steps []string
+	for _, s := range in.Steps {
+		steps = append(steps, fmt.Sprintf("%s/%d/%t", s.Step, s.Object, s.Error != ""))
+	}
+	want := "明文 XBRL/0/true EmbeddedFile/4/true EmbeddedFile/6/false 解析 XBRL/0/false 发票字段/0/false"
+	if got := strings.Join(steps, " "); got != want {
+		t.Fatalf("steps = %s, want %s", got, want)
+	}
+	if len(in.Facts) != 4 || in.Info == nil || in.Info.DepartureStation != "三门峡南" {
+		t.Fatalf("facts=%+v info=%+v", in.Facts, in.Info)
+	}
+}
+
+func TestDecodeLZW_EarlyChange(t *testing.T) {
+	in := bytes.Repeat([]byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"), 300)
+	var buf bytes.Buffer
+	w := lzw.NewWriter(&buf, lzw.MSB, 8)
+	w.Write(in)
+	w.Close()
+
+	out, err := decodeLZW(context.Background(), buf.Bytes(), 0)
+	if err != nil || !bytes.Equal(out, in) {
+		t.Fatalf("decodeLZW mismatch: err=%v len=%d want %d", err, len(out), len(in))
+	}
+}
+
+func TestDecodeFilters_RejectOversizedOutput(t *testing.T) {
+	ctx := context.Background()
+	bomb := compressZlib(make([]byte, pdfMaxStreamSize+1))
+	runs := bytes.Repeat([]byte{129, 'x'}, pdfMaxStreamSize/128+1)
+	cases := []struct {
+		name   string
+		decode streamDecoder
+		data   []byte
+		parms  pdfDict
+	}{
+		{"flate", decodeFlateFilter, bomb, nil},
+		{"runlength", decodeRunLengthFilter, runs, nil},
+		{"png columns", decodeFlateFilter, compressZlib([]byte{0, 1, 2}), pdfDict{pdfNamePredictor: 12, pdfNameColumns: 1 << 30}},
+		{"png row", decodeFlateFilter, compressZlib([]byte{0, 1, 2}), pdfDict{pdfNamePredictor: 12, pdfNameColors: 32, pdfNameColumns: 1 << 20}},
+		{"tiff colors", decodeFlateFilter, compressZlib([]byte{1, 2}), pdfDict{pdfNamePredictor: 2, pdfNameColors: 1 << 30}},
+	}
+	for _, c := range cases {
+		if out, err := c.decode(ctx, c.data, c.parms); err == nil {
+			t.Fatalf("%s: expected error, got %d bytes", c.name, len(out))
+		}
+	}
+	if !errors.Is(checkStreamSize(pdfMaxStreamSize+1), errStreamTooLarge) {
+		t.Fatalf("checkStreamSize should reject oversized output")
+	}
+}
+
+func TestParseXbrlDocument_FactsContextsAndConflicts(t *testing.T) {
+	head := `<xbrli:xbrl xmlns:xbrli="http://www.xbrl.org/2003/instance" xmlns:iso4217="http://www.xbrl.org/2003/iso4217" xmlns:rai="urn:rai" xmlns:other="urn:other">` +
+		`<xbrli:context id="c1"><xbrli:entity><xbrli:identifier scheme="urn:tax">91410100MA00000000</xbrli:identifier></xbrli:entity><xbrli:period><xbrli:instant>2026-02-28</xbrli:instant></xbrli:period></xbrli:context>` +
+		`<xbrli:unit id="CNY"><xbrli:measure>iso4217:CNY</xbrli:measure></xbrli:unit>` +
+		`<rai:TravelDate contextRef="c1">2026-02-11</rai:TravelDate><rai:DepartureStation contextRef="c1">三门峡南</rai:DepartureStation>` +
+		`<rai:DestinationStation contextRef="c1">郑州</rai:DestinationStation><rai:DestinationStation contextRef="c1">郑州</rai:DestinationStation>` +
+		`<rai:Fare contextRef="c1" unitRef="CNY" decimals="2">109.00</rai:Fare><rai:TicketFare contextRef="c1" unitRef="CNY" decimals="0">109</rai:TicketFare>`
+	tail := `</xbrli:xbrl>`
+
+	doc, err := ParseXbrlDocument([]byte(head + `<other:Note contextRef="c1">备注</other:Note>` + tail))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(doc.Facts) != 6 || doc.Contexts["c1"].Instant != "2026-02-28" || doc.Contexts["c1"].Entity.Scheme != "urn:tax" {
+		t.Fatalf("facts=%+v contexts=%+v", doc.Facts, doc.Contexts)
+	}
+	if u := doc.Units["CNY"]; len(u.Measures) != 1 || u.Measures[0] != "iso4217:CNY" {
+		t.Fatalf("units=%+v", doc.Units)
+	}
+	fare := doc.Facts[3]
+	if fare.Namespace != "urn:rai" || fare.Name != "Fare" || fare.ContextRef != "c1" || fare.UnitRef != "CNY" || fare.Decimals != "2" {
+		t.Fatalf("fare fact=%+v", fare)
+	}
+	info, err := doc.InvoiceInfo()
+	if err != nil || info.Fare != "109.00" || info.DestinationStation != "郑州" {
+		t.Fatalf("info=%+v err=%v", info, err)
+	}
+
+	for name, extra := range map[string]string{
+		"same fact":     `<rai:DepartureStation contextRef="c1">洛阳龙门</rai:DepartureStation>`,
+		"other unit":    `<rai:Fare contextRef="c1" unitRef="USD">109.00</rai:Fare>`,
+		"other context": `<rai:DepartureStation contextRef="c2">洛阳龙门</rai:DepartureStation>`,
+		"alias":         `<rai:TicketPrice contextRef="c1" unitRef="CNY">98.00</rai:TicketPrice>`,
+		"alias context": `<rai:SeatLevel contextRef="c1">二等座</rai:SeatLevel><rai:SeatType contextRef="c2">一等座</rai:SeatType>`,
+	} {
+		_, err := ParseInvoiceInfoFromXbrl([]byte(head + extra + tail))
+		if !errors.Is(err, ErrConflictingXbrlFacts) {
+			t.Fatalf("%s: expected conflict error, got %v", name, err)
+		}
+	}
+	for name, extra := range map[string]string{
+		"other namespace": `<other:DepartureStation contextRef="c1">洛阳龙门</other:DepartureStation><other:Fare contextRef="c1" unitRef="CNY">1.00</other:Fare>`,
+		"agreeing alias":  `<rai:TicketPrice contextRef="c2" unitRef="CNY">109</rai:TicketPrice><rai:DepartureStation contextRef="c2">三门峡南</rai:DepartureStation>`,
+	} {
+		info, err := ParseInvoiceInfoFromXbrl([]byte(head + extra + tail))
+		if err != nil || info.DepartureStation != "三门峡南" || info.Fare != "109.00" {
+			t.Fatalf("%s: info=%+v err=%v", name, info, err)
+		}
+	}
+
+	reordered := `<xbrl xmlns:rai="urn:rai" xmlns:ext="urn:ext"><ext:DepartureStation>洛阳龙门</ext:DepartureStation>` +
+		`<rai:TicketPrice contextRef="c2" unitRef="CNY">109</rai:TicketPrice>` +
+		`<rai:DepartureStation contextRef="c1">三门峡南</rai:DepartureStation><rai:DestinationStation contextRef="c1">郑州</rai:DestinationStation>` +
+		`<rai:TravelDate contextRef="c1">2026-02-11</rai:TravelDate><rai:Fare contextRef="c1" unitRef="CNY">109.00</rai:Fare></xbrl>`
+	if info, err := ParseInvoiceInfoFromXbrl([]byte(reordered)); err != nil || info.DepartureStation != "三门峡南" || info.Fare != "109.00" {
+		t.Fatalf("invoice namespace and context must not depend on document order: info=%+v err=%v", info, err)
+	}
+}
+
+func TestParseInvoiceInfoFromXbrl_FullFieldSet(t *testing.T) {
+	xbrl := `<xbrl xmlns:rai="urn:rai">` +
+		`<rai:EInvoiceNumber>26419000000123456789</rai:EInvoiceNumber>` +
+		`<rai:ElectronicTicketNumber>E123456789012345678</rai:ElectronicTicketNumber>` +
+		`<rai:TravelDate>2026-02-11</rai:TravelDate><rai:DateOfIssue>2026-02-28</rai:DateOfIssue>` +
+		`<rai:DepartureStation>三门峡南</rai:DepartureStation><rai:DestinationStation>郑州</rai:DestinationStation>` +
+		`<rai:TrainNumber>G1234</rai:TrainNumber><rai:SeatLevel>二等座</rai:SeatLevel>` +
+		`<rai:CarriageNumber>05</rai:CarriageNumber><rai:SeatNumber>12F</rai:SeatNumber>` +
+		`<rai:DepartureTime>08:15</rai:DepartureTime>` +
+		`<rai:NameOfPassenger>张三</rai:NameOfPassenger><rai:IDNumberOfPassenger>410102199001011234</rai:IDNumberOfPassenger>` +
+		`<rai:Fare>109.00</rai:Fare><rai:TaxRate>9%</rai:TaxRate><rai:TaxAmount>9.00</rai:TaxAmount>` +
+		`<rai:NameOfPurchaser>某某科技有限公司</rai:NameOfPurchaser>` +
+		`<rai:TaxpayerIdentificationNumberOfPurchaser>91410100MA00000000</rai:TaxpayerIdentificationNumberOfPurchaser>` +
+		`</xbrl>`
+
+	info, err := ParseInvoiceInfoFromXbrl([]byte(xbrl))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertInfo(t, info)
+
+	want := InvoiceInfo{
+		TravelDate:             info.TravelDate,
+		DateOfIssue:            info.DateOfIssue,
+		DepartureStation:       info.DepartureStation,
+		DestinationStation:     info.DestinationStation,
+		InvoiceNumber:          "26419000000123456789",
+		ElectronicTicketNumber: "E123456789012345678",
+		TrainNumber:            "G1234",
+		SeatClass:              "二等座",
+		Carriage:               "05",
+		SeatNumber:             "12F",
+		DepartureTime:          "08:15",
+		PassengerName:          "张三",
+		PassengerIDMasked:      "4101**********1234",
+		Fare:                   "109.00",
+		TaxRate:                "9%",
+		TaxAmount:              "9.00",
+		BuyerName:              "某某科技有限公司",
+		BuyerTaxID:             "91410100MA00000000",
+	}
+	if info != want {
+		t.Fatalf("info=%+v\nwant=%+v", info, want)
+	}
+}
+
+type pdfTestBuilder struct {
+	buf     bytes.Buffer
+	offsets map[int]int
+}
+
+func (b *pdfTestBuilder) header() {
+	b.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+}
+
+func (b *pdfTestBuilder) object(num int, body string) {
+	b.mark(num)
+	fmt.Fprintf(&b.buf, "%d 0 obj\n%s\nendobj\n", num, body)
+}
+
+func (b *pdfTestBuilder) stream(num int, dict string, data []byte) {
+	b.mark(num)
+	fmt.Fprintf(&b.buf, "%d 0 obj\n<<%s>>stream\n", num, dict)
+	b.buf.Write(data)
+	b.buf.WriteString("\nendstream\nendobj\n")
+}
+
+func (b *pdfTestBuilder) mark(num int) {
+	if b.offsets == nil {
+		b.offsets = make(map[int]int)
+	}
+	b.offsets[num] = b.buf.Len()
+}
+
+func (b *pdfTestBuilder) xref(trailer string) int {
+	start := b.buf.Len()
+	nums := make([]int, 0, len(b.offsets))
+	for num := range b.offsets {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	b.buf.WriteString("xref\n0 1\n0000000000 65535 f\r\n")
+	for _, num := range nums {
+		fmt.Fprintf(&b.buf, "%d 1\n%010d 00000 n\r\n", num, b.offsets[num])
+	}
+	fmt.Fprintf(&b.buf, "trailer\n<<%s>>\nstartxref\n%d\n%%%%EOF\n", trailer, start)
+	b.offsets = nil
+	return start
+}
+
+type testEncryption struct {
+	v, r        int
+	keyLen      int
+	cfm         string
+	user, owner string
+}
+
+func (e testEncryption) build(t *testing.T, xml []byte) []byte {
+	t.Helper()
+	id0 := []byte("0123456789abcdef")
+	h := &securityHandler{v: e.v, r: e.r, keyLen: e.keyLen, p: -4, id0: id0, encryptMetadata: true}
+
+	var encDict string
+	if e.r >= 5 {
+		h.key = bytes.Repeat([]byte{0x5A}, 32)
+		h.u, h.ue = e.wrapKeyR6(h, []byte(e.user), []byte("uvalsalt"), []byte("ukeysalt"), nil)
+		h.o, h.oe = e.wrapKeyR6(h, []byte(e.owner), []byte("ovalsalt"), []byte("okeysalt"), h.u)
+		encDict = fmt.Sprintf("/OE<%x>/UE<%x>", h.oe, h.ue)
+	} else {
+		h.o = e.ownerEntry()
+		h.key = e.fileKey(h.o, id0)
+		h.u = e.userEntry(h.key, id0)
+	}
+	method := cryptRC4
+	if e.cfm != "" {
+		method = cryptMethodsByCFM[pdfName(e.cfm)]
+		encDict += fmt.Sprintf("/CF<</StdCF<</CFM/%s/Length %d>>>>/StmF/StdCF/StrF/StdCF", e.cfm, e.keyLen)
+	}
+
+	plain := compressZlib(xml)
+	data := rc4Crypt(h.objectKey(method, objectRef{objNum: 5}), plain)
+	if method != cryptRC4 {
+		block, _ := aes.NewCipher(h.objectKey(method, objectRef{objNum: 5}))
+		pad := aes.BlockSize - len(plain)%aes.BlockSize
+		padded := append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+		iv := []byte("initialvector16b")
+		data = append(append([]byte(nil), iv...), make([]byte, len(padded))...)
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(data[aes.BlockSize:], padded)
+	}
+
+	var b pdfTestBuilder
+	b.header()
+	b.object(1, "<</Type/Catalog>>")
+	b.object(2, fmt.Sprintf("<</Filter/Standard/V %d/R %d/Length %d/P -4/O<%x>/U<%x>%s>>", e.v, e.r, e.keyLen*8, h.o, h.u, encDict))
+	b.stream(5, fmt.Sprintf("/Type/EmbeddedFile/Filter/FlateDecode/Length %d", len(data)), data)
+	b.xref(fmt.Sprintf("/Size 6/Root 1 0 R/Encrypt 2 0 R/ID[<%x><%x>]", id0, id0))
+	return b.buf.Bytes()
+}
+
+func (e testEncryption) ownerEntry() []byte {
+	sum := md5.Sum(padPassword([]byte(e.owner)))
+	key := sum[:]
+	if e.r >= 3 {
+		for i := 0; i < 50; i++ {
+			sum = md5.Sum(key)
+			key = sum[:]
+		}
+	}
+	key = key[:e.keyLen]
+	out := rc4Crypt(key, padPassword([]byte(e.user)))
+	if e.r >= 3 {
+		for i := 1; i <= 19; i++ {
+			out = rc4Crypt(xorKey(key, byte(i)), out)
+		}
+	}
+	return out
+}
+
+func (e testEncryption) fileKey(o, id0 []byte) []byte {
+	in := append(padPassword([]byte(e.user)), o...)
+	in = append(in, 0xFC, 0xFF, 0xFF, 0xFF)
+	sum := md5.Sum(append(in, id0...))
+	key := sum[:e.keyLen]
+	if e.r >= 3 {
+		for i := 0; i < 50; i++ {
+			sum = md5.Sum(key)
+			key = sum[:e.keyLen]
+		}
+	}
+	return key
+}
+
+func (e testEncryption) userEntry(key, id0 []byte) []byte {
+	if e.r == 2 {
+		return rc4Crypt(key, pdfPasswordPadding)
+	}
+	sum := md5.Sum(append(append([]byte(nil), pdfPasswordPadding...), id0...))
+	out := rc4Crypt(key, sum[:])
+	for i := 1; i <= 19; i++ {
+		out = rc4Crypt(xorKey(key, byte(i)), out)
+	}
+	return append(out, make([]byte, 16)...)
+}
+
+func (e testEncryption) wrapKeyR6(h *securityHandler, password, validationSalt, keySalt, udata []byte) ([]byte, []byte) {
+	entry := append(append(h.passwordHash(password, validationSalt, udata), validationSalt...), keySalt...)
+	block, _ := aes.NewCipher(h.passwordHash(password, keySalt, udata))
+	wrapped := make([]byte, len(h.key))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(wrapped, h.key)
+	return entry, wrapped
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
 func compressZlib(in []byte) []byte {
 	var buf bytes.Buffer
 	w := zlib.NewWriter(&buf)
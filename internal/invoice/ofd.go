@@ -0,0 +1,330 @@
+package invoice
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	OFDExt = ".ofd"
+
+	ofdRootEntry    = "OFD.xml"
+	ofdMaxEntrySize = 32 << 20
+	ofdObjectRefTag = "ObjectRef"
+	ofdTextObject   = "TextObject"
+	ofdTextCode     = "TextCode"
+	ofdIDAttr       = "ID"
+)
+
+var ofdCustomDataNames = map[string]string{
+	"发票号码":      "InvoiceNumber",
+	"开票日期":      "DateOfIssue",
+	"乘车日期":      "TravelDate",
+	"出发站":       "DepartureStation",
+	"到达站":       "DestinationStation",
+	"车次":        "TrainNumber",
+	"电子客票号":     "ElectronicTicketNumber",
+	"票价":        "Fare",
+	"税率":        "TaxRate",
+	"税额":        "TaxAmount",
+	"合计税额":      "TaxAmount",
+	"购买方名称":     "BuyerName",
+	"购买方纳税人识别号": "BuyerTaxID",
+}
+
+type ofdMain struct {
+	DocBodies []struct {
+		CustomDatas []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"DocInfo>CustomDatas>CustomData"`
+		DocRoot string `xml:"DocRoot"`
+	} `xml:"DocBody"`
+}
+
+type ofdDocumentXML struct {
+	Pages []struct {
+		BaseLoc string `xml:"BaseLoc,attr"`
+	} `xml:"Pages>Page"`
+	Attachments string `xml:"Attachments"`
+	CustomTags  string `xml:"CustomTags"`
+}
+
+type ofdAttachmentsXML struct {
+	Attachments []struct {
+		Name    string `xml:"Name,attr"`
+		FileLoc string `xml:"FileLoc"`
+	} `xml:"Attachment"`
+}
+
+type ofdCustomTagsXML struct {
+	Tags []struct {
+		FileLoc string `xml:"FileLoc"`
+	} `xml:"CustomTag"`
+}
+
+type ofdNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Nodes   []ofdNode  `xml:",any"`
+}
+
+type ofdPackage struct {
+	ctx     context.Context
+	files   map[string]*zip.File
+	pages   []string
+	texts   map[string]string
+	readErr error
+}
+
+func ExtractInvoiceInfoFromOFDBytes(ctx context.Context, ofdBytes []byte) (InvoiceInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return InvoiceInfo{}, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(ofdBytes), int64(len(ofdBytes)))
+	if err != nil {
+		return InvoiceInfo{}, fmt.Errorf("解析 OFD 失败: %w", err)
+	}
+	pkg := &ofdPackage{ctx: ctx, files: make(map[string]*zip.File)}
+	for _, f := range zr.File {
+		pkg.files[strings.ToLower(ofdResolve("", f.Name))] = f
+	}
+
+	var root ofdMain
+	if err := pkg.readXML(ofdRootEntry, &root); err != nil {
+		return InvoiceInfo{}, err
+	}
+	if len(root.DocBodies) == 0 {
+		return InvoiceInfo{}, errors.New("OFD.xml 缺少 DocBody")
+	}
+
+	info := InvoiceInfo{}
+	for _, body := range root.DocBodies {
+		if strings.TrimSpace(body.DocRoot) != "" {
+			if err := pkg.readDocument(ofdResolve("", body.DocRoot), &info); err != nil {
+				return InvoiceInfo{}, err
+			}
+		}
+		for _, cd := range body.CustomDatas {
+			name, value := strings.TrimSpace(cd.Name), strings.TrimSpace(cd.Value)
+			if tag, ok := ofdCustomDataNames[name]; ok {
+				name = tag
+			}
+			setOFDFieldIfEmpty(&info, name, value)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return InvoiceInfo{}, err
+	}
+
+	out, err := finishInvoiceInfo(info)
+	if errors.Is(err, errMissingRequiredField) && pkg.readErr != nil {
+		return InvoiceInfo{}, pkg.readErr
+	}
+	return out, err
+}
+
+func (p *ofdPackage) readDocument(docPath string, info *InvoiceInfo) error {
+	var doc ofdDocumentXML
+	if err := p.readXML(docPath, &doc); err != nil {
+		return err
+	}
+	dir := path.Dir(docPath)
+	for _, page := range doc.Pages {
+		p.pages = append(p.pages, ofdResolve(dir, page.BaseLoc))
+	}
+	if strings.TrimSpace(doc.Attachments) != "" {
+		if err := p.readAttachments(ofdResolve(dir, doc.Attachments), info); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(doc.CustomTags) != "" {
+		p.readCustomTags(ofdResolve(dir, doc.CustomTags), info)
+	}
+	return p.ctx.Err()
+}
+
+func (p *ofdPackage) readAttachments(listPath string, info *InvoiceInfo) error {
+	var list ofdAttachmentsXML
+	if err := p.readXML(listPath, &list); err != nil {
+		p.readErr = firstNonNil(p.readErr, err)
+		return nil
+	}
+	dir := path.Dir(listPath)
+	for _, a := range list.Attachments {
+		loc := ofdResolve(dir, a.FileLoc)
+		if !isXbrlFileName(loc) && !isXbrlFileName(a.Name) {
+			continue
+		}
+		b, err := p.read(loc)
+		if err != nil {
+			p.readErr = firstNonNil(p.readErr, err)
+			continue
+		}
+		if xbrl, ok := extractPlainXbrl(b); ok {
+			b = xbrl
+		}
+		doc, err := ParseXbrlDocument(b)
+		if err == nil {
+			err = doc.applyTo(info)
+		}
+		if err != nil {
+			err = fmt.Errorf("OFD 附件 %s: %w", a.Name, err)
+			if errors.Is(err, ErrConflictingXbrlFacts) {
+				return err
+			}
+			p.readErr = firstNonNil(p.readErr, err)
+		}
+	}
+	return nil
+}
+
+func (p *ofdPackage) readCustomTags(listPath string, info *InvoiceInfo) {
+	var list ofdCustomTagsXML
+	if err := p.readXML(listPath, &list); err != nil {
+		p.readErr = firstNonNil(p.readErr, err)
+		return
+	}
+	dir := path.Dir(listPath)
+	for _, tag := range list.Tags {
+		var root ofdNode
+		if err := p.readXML(ofdResolve(dir, tag.FileLoc), &root); err != nil {
+			p.readErr = firstNonNil(p.readErr, err)
+			continue
+		}
+		p.applyCustomTag(root, info)
+	}
+}
+
+func (p *ofdPackage) applyCustomTag(n ofdNode, info *InvoiceInfo) {
+	if isOFDFieldTag(n.XMLName.Local) {
+		setOFDFieldIfEmpty(info, n.XMLName.Local, p.customTagValue(n))
+		return
+	}
+	for _, child := range n.Nodes {
+		p.applyCustomTag(child, info)
+	}
+}
+
+func (p *ofdPackage) customTagValue(n ofdNode) string {
+	var refs []string
+	for _, child := range n.Nodes {
+		if child.XMLName.Local == ofdObjectRefTag {
+			refs = append(refs, strings.TrimSpace(child.Text))
+		}
+	}
+	if len(refs) == 0 {
+		return strings.TrimSpace(n.Text)
+	}
+	texts := p.textObjects()
+	var sb strings.Builder
+	for _, id := range refs {
+		sb.WriteString(texts[id])
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func (p *ofdPackage) textObjects() map[string]string {
+	if p.texts != nil {
+		return p.texts
+	}
+	p.texts = make(map[string]string)
+	for _, page := range p.pages {
+		var root ofdNode
+		if err := p.readXML(page, &root); err != nil {
+			p.readErr = firstNonNil(p.readErr, err)
+			continue
+		}
+		collectOFDText(root, p.texts)
+	}
+	return p.texts
+}
+
+func collectOFDText(n ofdNode, texts map[string]string) {
+	if n.XMLName.Local != ofdTextObject {
+		for _, child := range n.Nodes {
+			collectOFDText(child, texts)
+		}
+		return
+	}
+	var sb strings.Builder
+	for _, child := range n.Nodes {
+		if child.XMLName.Local == ofdTextCode {
+			sb.WriteString(child.Text)
+		}
+	}
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == ofdIDAttr {
+			texts[strings.TrimSpace(attr.Value)] = sb.String()
+		}
+	}
+}
+
+func (p *ofdPackage) readXML(name string, v interface{}) error {
+	b, err := p.read(name)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("解析 OFD 文件 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+func (p *ofdPackage) read(name string) ([]byte, error) {
+	if err := p.ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, ok := p.files[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("OFD 缺少文件 %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取 OFD 文件 %s 失败: %w", name, err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(contextReader{ctx: p.ctx, r: rc}, ofdMaxEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取 OFD 文件 %s 失败: %w", name, err)
+	}
+	if len(b) > ofdMaxEntrySize {
+		return nil, fmt.Errorf("OFD 文件 %s 过大", name)
+	}
+	return b, nil
+}
+
+func ofdResolve(base string, loc string) string {
+	loc = strings.ReplaceAll(strings.TrimSpace(loc), "\\", "/")
+	if strings.HasPrefix(loc, "/") {
+		return path.Clean(strings.TrimPrefix(loc, "/"))
+	}
+	return path.Clean(path.Join(base, loc))
+}
+
+func isOFDFieldTag(local string) bool {
+	_, ok := xbrlFieldTags[local]
+	return ok
+}
+
+func setOFDFieldIfEmpty(info *InvoiceInfo, local string, value string) {
+	if value == "" {
+		return
+	}
+	field, ok := xbrlFieldTags[local]
+	if !ok {
+		return
+	}
+	dst := field(info)
+	if *dst == "" {
+		*dst = value
+	}
+}
@@ -0,0 +1,209 @@
+package invoice
+
+import (
+	"sort"
+	"unicode/utf16"
+)
+
+const (
+	cmapBeginCodespace = "begincodespacerange"
+	cmapEndCodespace   = "endcodespacerange"
+	cmapBeginBFChar    = "beginbfchar"
+	cmapEndBFChar      = "endbfchar"
+	cmapBeginBFRange   = "beginbfrange"
+	cmapEndBFRange     = "endbfrange"
+
+	cmapMaxCodeLen = 4
+)
+
+type cmapCode struct {
+	code uint32
+	n    int
+}
+
+type cmapSpace struct {
+	lo, hi uint32
+	n      int
+}
+
+type cmapRange struct {
+	lo, hi uint32
+	n      int
+	dst    []uint16
+	list   []string
+}
+
+type toUnicodeCMap struct {
+	spaces []cmapSpace
+	lens   []int
+	chars  map[cmapCode]string
+	ranges []cmapRange
+}
+
+func parseToUnicodeCMap(data []byte) *toUnicodeCMap {
+	m := &toUnicodeCMap{chars: make(map[cmapCode]string)}
+	p := newPDFParser(data, 0)
+	var operands []pdfObject
+	for {
+		p.lex.skipSpaceAndComments()
+		if p.lex.pos >= len(data) {
+			break
+		}
+		obj, err := p.parseObject()
+		if err != nil {
+			p.lex.pos++
+			operands = nil
+			continue
+		}
+		kw, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		switch kw {
+		case cmapEndCodespace:
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, _ := operands[i].(pdfString)
+				hi, _ := operands[i+1].(pdfString)
+				if len(lo) > 0 && len(lo) <= cmapMaxCodeLen && len(lo) == len(hi) {
+					m.spaces = append(m.spaces, cmapSpace{lo: codeValue(lo), hi: codeValue(hi), n: len(lo)})
+				}
+			}
+		case cmapEndBFChar:
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, _ := operands[i].(pdfString)
+				dst, _ := operands[i+1].(pdfString)
+				if len(src) > 0 && len(src) <= cmapMaxCodeLen {
+					m.chars[cmapCode{code: codeValue(src), n: len(src)}] = utf16BEString(dst)
+				}
+			}
+		case cmapEndBFRange:
+			for i := 0; i+2 < len(operands); i += 3 {
+				m.addRange(operands[i], operands[i+1], operands[i+2])
+			}
+		}
+		operands = nil
+	}
+	m.codeLengths()
+	return m
+}
+
+func (m *toUnicodeCMap) addRange(loObj, hiObj, dstObj pdfObject) {
+	lo, _ := loObj.(pdfString)
+	hi, _ := hiObj.(pdfString)
+	if len(lo) == 0 || len(lo) > cmapMaxCodeLen || len(lo) != len(hi) {
+		return
+	}
+	r := cmapRange{lo: codeValue(lo), hi: codeValue(hi), n: len(lo)}
+	switch dst := dstObj.(type) {
+	case pdfString:
+		r.dst = utf16Units(dst)
+	case pdfArray:
+		for _, item := range dst {
+			s, _ := item.(pdfString)
+			r.list = append(r.list, utf16BEString(s))
+		}
+	default:
+		return
+	}
+	m.ranges = append(m.ranges, r)
+}
+
+func (m *toUnicodeCMap) codeLengths() {
+	seen := make(map[int]bool)
+	add := func(n int) {
+		if !seen[n] {
+			seen[n] = true
+			m.lens = append(m.lens, n)
+		}
+	}
+	for _, s := range m.spaces {
+		add(s.n)
+	}
+	if len(m.lens) == 0 {
+		for c := range m.chars {
+			add(c.n)
+		}
+		for _, r := range m.ranges {
+			add(r.n)
+		}
+	}
+	if len(m.lens) == 0 {
+		add(1)
+	}
+	sort.Ints(m.lens)
+}
+
+func (m *toUnicodeCMap) decode(b []byte) string {
+	var out []rune
+	for i := 0; i < len(b); {
+		n := m.codeLen(b[i:])
+		if s, ok := m.lookup(codeValue(b[i:i+n]), n); ok {
+			out = append(out, []rune(s)...)
+		} else if n == 1 && b[i] >= 0x20 && b[i] < 0x7F {
+			out = append(out, rune(b[i]))
+		}
+		i += n
+	}
+	return string(out)
+}
+
+func (m *toUnicodeCMap) codeLen(b []byte) int {
+	for _, n := range m.lens {
+		if n > len(b) {
+			break
+		}
+		code := codeValue(b[:n])
+		for _, s := range m.spaces {
+			if s.n == n && code >= s.lo && code <= s.hi {
+				return n
+			}
+		}
+	}
+	return min(m.lens[0], len(b))
+}
+
+func (m *toUnicodeCMap) lookup(code uint32, n int) (string, bool) {
+	if s, ok := m.chars[cmapCode{code: code, n: n}]; ok {
+		return s, true
+	}
+	for _, r := range m.ranges {
+		if r.n != n || code < r.lo || code > r.hi {
+			continue
+		}
+		off := code - r.lo
+		if r.list != nil {
+			if int(off) < len(r.list) {
+				return r.list[off], true
+			}
+			return "", false
+		}
+		if len(r.dst) == 0 {
+			return "", false
+		}
+		units := append([]uint16(nil), r.dst...)
+		units[len(units)-1] += uint16(off)
+		return string(utf16.Decode(units)), true
+	}
+	return "", false
+}
+
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func utf16Units(b []byte) []uint16 {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return units
+}
+
+func utf16BEString(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
@@ -0,0 +1,256 @@
+package invoice
+
+import (
+	"bytes"
+	"math"
+)
+
+const (
+	pdfNameXObject pdfName = "XObject"
+	pdfNameForm    pdfName = "Form"
+	pdfNameMatrix  pdfName = "Matrix"
+
+	pdfMaxFormDepth   = 8
+	pdfInlineImageEnd = "EI"
+)
+
+type pdfMatrix [6]float64
+
+var identityMatrix = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+func (m pdfMatrix) multiply(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translateMatrix(tx, ty float64) pdfMatrix {
+	return pdfMatrix{1, 0, 0, 1, tx, ty}
+}
+
+type textRun struct {
+	x, y  float64
+	size  float64
+	width float64
+	text  string
+}
+
+type contentReader struct {
+	doc   *pdfDocument
+	fonts map[objectRef]*pdfFont
+	runs  []textRun
+}
+
+type textState struct {
+	ctm     pdfMatrix
+	stack   []pdfMatrix
+	tm, tlm pdfMatrix
+	font    *pdfFont
+	size    float64
+	leading float64
+}
+
+func (d *pdfDocument) pageTextRuns(page pdfPage) []textRun {
+	c := &contentReader{doc: d, fonts: make(map[objectRef]*pdfFont)}
+	c.read(d.pageContent(page), page.resources, identityMatrix, 0)
+	return c.runs
+}
+
+func (c *contentReader) read(data []byte, resources pdfDict, ctm pdfMatrix, depth int) {
+	st := &textState{ctm: ctm, tm: identityMatrix, tlm: identityMatrix, font: &pdfFont{}}
+	p := newPDFParser(data, 0)
+	var operands []pdfObject
+	for {
+		if c.doc.ctx.Err() != nil {
+			return
+		}
+		p.lex.skipSpaceAndComments()
+		if p.lex.pos >= len(data) {
+			return
+		}
+		obj, err := p.parseObject()
+		if err != nil {
+			p.lex.pos++
+			operands = nil
+			continue
+		}
+		op, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+		if op == "BI" {
+			skipInlineImage(&p.lex)
+		} else {
+			c.apply(st, op, operands, resources, depth)
+		}
+		operands = nil
+	}
+}
+
+func (c *contentReader) apply(st *textState, op pdfKeyword, args []pdfObject, resources pdfDict, depth int) {
+	switch op {
+	case "q":
+		st.stack = append(st.stack, st.ctm)
+	case "Q":
+		if n := len(st.stack); n > 0 {
+			st.ctm, st.stack = st.stack[n-1], st.stack[:n-1]
+		}
+	case "cm":
+		if m, ok := matrixArgs(args); ok {
+			st.ctm = m.multiply(st.ctm)
+		}
+	case "BT":
+		st.tm, st.tlm = identityMatrix, identityMatrix
+	case "Tf":
+		if len(args) == 2 {
+			name, _ := args[0].(pdfName)
+			st.font = c.doc.loadFont(resources, name, c.fonts)
+			st.size, _ = pdfNumber(args[1])
+		}
+	case "TL":
+		if len(args) == 1 {
+			st.leading, _ = pdfNumber(args[0])
+		}
+	case "Td", "TD":
+		if len(args) == 2 {
+			tx, _ := pdfNumber(args[0])
+			ty, _ := pdfNumber(args[1])
+			if op == "TD" {
+				st.leading = -ty
+			}
+			st.nextLine(tx, ty)
+		}
+	case "Tm":
+		if m, ok := matrixArgs(args); ok {
+			st.tm, st.tlm = m, m
+		}
+	case "T*":
+		st.nextLine(0, -st.leading)
+	case "Tj":
+		if len(args) == 1 {
+			c.show(st, args[0])
+		}
+	case "'", "\"":
+		st.nextLine(0, -st.leading)
+		if len(args) > 0 {
+			c.show(st, args[len(args)-1])
+		}
+	case "TJ":
+		if len(args) == 1 {
+			arr, _ := args[0].(pdfArray)
+			for _, item := range arr {
+				if n, ok := pdfNumber(item); ok {
+					st.tm = translateMatrix(-n/1000*st.size, 0).multiply(st.tm)
+					continue
+				}
+				c.show(st, item)
+			}
+		}
+	case "Do":
+		if len(args) == 1 && depth < pdfMaxFormDepth {
+			name, _ := args[0].(pdfName)
+			c.form(st, resources, name, depth)
+		}
+	}
+}
+
+func (st *textState) nextLine(tx, ty float64) {
+	st.tlm = translateMatrix(tx, ty).multiply(st.tlm)
+	st.tm = st.tlm
+}
+
+func (c *contentReader) show(st *textState, obj pdfObject) {
+	s, ok := obj.(pdfString)
+	if !ok {
+		return
+	}
+	text := st.font.decode(s)
+	if text == "" {
+		return
+	}
+	trm := st.tm.multiply(st.ctm)
+	scale := math.Hypot(trm[2], trm[3])
+	if scale == 0 {
+		scale = 1
+	}
+	width := estimateTextWidth(text) * st.size
+	c.runs = append(c.runs, textRun{x: trm[4], y: trm[5], size: st.size * scale, width: width * math.Hypot(trm[0], trm[1]), text: text})
+	st.tm = translateMatrix(width, 0).multiply(st.tm)
+}
+
+func (c *contentReader) form(st *textState, resources pdfDict, name pdfName, depth int) {
+	xobjects, ok := c.doc.resolveDict(resources[pdfNameXObject])
+	if !ok {
+		return
+	}
+	obj, err := c.doc.resolve(xobjects[name])
+	if err != nil {
+		return
+	}
+	s, ok := obj.(pdfStream)
+	if !ok || s.dict[pdfNameSubtype] != pdfNameForm {
+		return
+	}
+	data, err := c.doc.decodeStream(s)
+	if err != nil {
+		return
+	}
+	formRes := resources
+	if own, ok := c.doc.resolveDict(s.dict[pdfNameResources]); ok {
+		formRes = own
+	}
+	ctm := st.ctm
+	if arr, ok := c.doc.resolveArray(s.dict[pdfNameMatrix]); ok {
+		if m, ok := matrixArgs(arr); ok {
+			ctm = m.multiply(ctm)
+		}
+	}
+	c.read(data, formRes, ctm, depth+1)
+}
+
+func skipInlineImage(lex *pdfLexer) {
+	for {
+		idx := bytes.Index(lex.data[lex.pos:], []byte(pdfInlineImageEnd))
+		if idx < 0 {
+			lex.pos = len(lex.data)
+			return
+		}
+		at := lex.pos + idx
+		lex.pos = at + len(pdfInlineImageEnd)
+		if at > 0 && isWhitespace(lex.data[at-1]) && (lex.pos >= len(lex.data) || isWhitespace(lex.data[lex.pos])) {
+			return
+		}
+	}
+}
+
+func matrixArgs(args []pdfObject) (pdfMatrix, bool) {
+	var m pdfMatrix
+	if len(args) != len(m) {
+		return m, false
+	}
+	for i, a := range args {
+		v, ok := pdfNumber(a)
+		if !ok {
+			return m, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
+
+func pdfNumber(obj pdfObject) (float64, bool) {
+	switch v := obj.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
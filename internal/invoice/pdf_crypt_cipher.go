@@ -0,0 +1,59 @@
+package invoice
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"errors"
+)
+
+var aesSaltSuffix = []byte("sAlT")
+
+func (h *securityHandler) objectKey(method cryptMethod, ref objectRef) []byte {
+	if method == cryptAESV3 {
+		return h.key
+	}
+	b := append([]byte(nil), h.key...)
+	b = append(b, byte(ref.objNum), byte(ref.objNum>>8), byte(ref.objNum>>16), byte(ref.genNum), byte(ref.genNum>>8))
+	if method == cryptAESV2 {
+		b = append(b, aesSaltSuffix...)
+	}
+	sum := md5.Sum(b)
+	return sum[:min(len(h.key)+5, md5.Size)]
+}
+
+func (h *securityHandler) decrypt(method cryptMethod, ref objectRef, data []byte) ([]byte, error) {
+	switch method {
+	case cryptIdentity:
+		return data, nil
+	case cryptRC4:
+		return rc4Crypt(h.objectKey(method, ref), data), nil
+	default:
+		return aesCBCDecrypt(h.objectKey(method, ref), data)
+	}
+}
+
+func aesCBCDecrypt(key []byte, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	if len(data) < aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("AES 密文长度无效")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	iv, body := data[:aes.BlockSize], data[aes.BlockSize:]
+	if len(body) == 0 {
+		return body, nil
+	}
+	out := make([]byte, len(body))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, body)
+
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, errors.New("AES 填充无效")
+	}
+	return out[:len(out)-pad], nil
+}
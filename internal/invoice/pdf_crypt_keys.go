@@ -0,0 +1,212 @@
+package invoice
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"hash"
+)
+
+const (
+	pdfPasswordLen       = 32
+	pdfMaxPasswordLenR6  = 127
+	pdfKeyHashIterations = 50
+	pdfRC4Iterations     = 20
+	pdfSaltLen           = 8
+	pdfHashLen           = 32
+)
+
+var pdfPasswordPadding = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+func (h *securityHandler) authenticate(password []byte) ([]byte, bool) {
+	if h.r >= 5 {
+		if key, ok := h.userKeyAES(password); ok {
+			return key, true
+		}
+		return h.ownerKeyAES(password)
+	}
+	if key, ok := h.userKeyRC4(password); ok {
+		return key, true
+	}
+	return h.userKeyRC4(h.ownerToUserPassword(password))
+}
+
+func padPassword(password []byte) []byte {
+	out := make([]byte, 0, pdfPasswordLen)
+	if len(password) > pdfPasswordLen {
+		password = password[:pdfPasswordLen]
+	}
+	out = append(out, password...)
+	return append(out, pdfPasswordPadding[:pdfPasswordLen-len(out)]...)
+}
+
+func (h *securityHandler) fileKeyRC4(password []byte) []byte {
+	m := md5.New()
+	m.Write(padPassword(password))
+	m.Write(h.o[:pdfPasswordLen])
+	var p [4]byte
+	binary.LittleEndian.PutUint32(p[:], uint32(h.p))
+	m.Write(p[:])
+	m.Write(h.id0)
+	if h.r >= 4 && !h.encryptMetadata {
+		m.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	}
+	key := m.Sum(nil)
+	if h.r >= 3 {
+		for i := 0; i < pdfKeyHashIterations; i++ {
+			sum := md5.Sum(key[:h.keyLen])
+			key = sum[:]
+		}
+	}
+	return key[:h.keyLen]
+}
+
+func (h *securityHandler) userKeyRC4(password []byte) ([]byte, bool) {
+	key := h.fileKeyRC4(password)
+	if h.r == 2 {
+		return key, bytes.Equal(rc4Crypt(key, pdfPasswordPadding), h.u[:pdfPasswordLen])
+	}
+	m := md5.New()
+	m.Write(pdfPasswordPadding)
+	m.Write(h.id0)
+	out := m.Sum(nil)
+	for i := 0; i < pdfRC4Iterations; i++ {
+		out = rc4Crypt(xorKey(key, byte(i)), out)
+	}
+	return key, bytes.Equal(out, h.u[:md5.Size])
+}
+
+func (h *securityHandler) ownerToUserPassword(password []byte) []byte {
+	sum := md5.Sum(padPassword(password))
+	key := sum[:]
+	if h.r >= 3 {
+		for i := 0; i < pdfKeyHashIterations; i++ {
+			sum = md5.Sum(key)
+			key = sum[:]
+		}
+	}
+	key = key[:h.keyLen]
+
+	user := append([]byte(nil), h.o[:pdfPasswordLen]...)
+	if h.r == 2 {
+		return rc4Crypt(key, user)
+	}
+	for i := pdfRC4Iterations - 1; i >= 0; i-- {
+		user = rc4Crypt(xorKey(key, byte(i)), user)
+	}
+	return user
+}
+
+func (h *securityHandler) userKeyAES(password []byte) ([]byte, bool) {
+	password = truncatePasswordR6(password)
+	validation, keySalt := h.u[pdfHashLen:pdfHashLen+pdfSaltLen], h.u[pdfHashLen+pdfSaltLen:pdfHashLen+2*pdfSaltLen]
+	if !bytes.Equal(h.passwordHash(password, validation, nil), h.u[:pdfHashLen]) {
+		return nil, false
+	}
+	return aesUnwrapKey(h.passwordHash(password, keySalt, nil), h.ue[:pdfHashLen])
+}
+
+func (h *securityHandler) ownerKeyAES(password []byte) ([]byte, bool) {
+	password = truncatePasswordR6(password)
+	udata := h.u[:pdfHashLen+2*pdfSaltLen]
+	validation, keySalt := h.o[pdfHashLen:pdfHashLen+pdfSaltLen], h.o[pdfHashLen+pdfSaltLen:pdfHashLen+2*pdfSaltLen]
+	if !bytes.Equal(h.passwordHash(password, validation, udata), h.o[:pdfHashLen]) {
+		return nil, false
+	}
+	return aesUnwrapKey(h.passwordHash(password, keySalt, udata), h.oe[:pdfHashLen])
+}
+
+func truncatePasswordR6(password []byte) []byte {
+	if len(password) > pdfMaxPasswordLenR6 {
+		return password[:pdfMaxPasswordLenR6]
+	}
+	return password
+}
+
+func aesUnwrapKey(kek []byte, wrapped []byte) ([]byte, bool) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, false
+	}
+	key := make([]byte, len(wrapped))
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, wrapped)
+	return key, true
+}
+
+func (h *securityHandler) passwordHash(password, salt, udata []byte) []byte {
+	k := sha256Sum(password, salt, udata)
+	if h.r == 5 {
+		return k
+	}
+
+	var e []byte
+	for round := 0; round < 64 || int(e[len(e)-1]) > round-32; round++ {
+		k1 := bytes.Repeat(concatBytes(password, k, udata), 64)
+		block, _ := aes.NewCipher(k[:16])
+		e = make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+
+		var next hash.Hash
+		switch sumMod3(e[:16]) {
+		case 0:
+			next = sha256.New()
+		case 1:
+			next = sha512.New384()
+		default:
+			next = sha512.New()
+		}
+		next.Write(e)
+		k = next.Sum(nil)
+	}
+	return k[:pdfHashLen]
+}
+
+func sumMod3(b []byte) int {
+	sum := 0
+	for _, c := range b {
+		sum += int(c)
+	}
+	return sum % 3
+}
+
+func sha256Sum(parts ...[]byte) []byte {
+	s := sha256.New()
+	for _, p := range parts {
+		s.Write(p)
+	}
+	return s.Sum(nil)
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func xorKey(key []byte, x byte) []byte {
+	out := make([]byte, len(key))
+	for i, b := range key {
+		out[i] = b ^ x
+	}
+	return out
+}
+
+func rc4Crypt(key []byte, data []byte) []byte {
+	c, err := rc4.NewCipher(key)
+	if err != nil {
+		return nil
+	}
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
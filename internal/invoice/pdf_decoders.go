@@ -0,0 +1,186 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"encoding/ascii85"
+	"errors"
+	"fmt"
+)
+
+const (
+	pdfNameEarlyChange pdfName = "EarlyChange"
+
+	lzwClearCode   = 256
+	lzwEODCode     = 257
+	lzwFirstCode   = 258
+	lzwMinCodeBits = 9
+	lzwMaxCodeBits = 12
+
+	runLengthEOD = 128
+)
+
+func decodeFlateFilter(ctx context.Context, data []byte, parms pdfDict) ([]byte, error) {
+	out, err := decompressFlate(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return applyPredictor(out, parms)
+}
+
+func decodeLZWFilter(ctx context.Context, data []byte, parms pdfDict) ([]byte, error) {
+	earlyChange := 1
+	if v, ok := parms[pdfNameEarlyChange].(int); ok {
+		earlyChange = v
+	}
+	out, err := decodeLZW(ctx, data, earlyChange)
+	if err != nil {
+		return nil, err
+	}
+	return applyPredictor(out, parms)
+}
+
+func decodeLZW(ctx context.Context, data []byte, earlyChange int) ([]byte, error) {
+	var (
+		out      []byte
+		table    [][]byte
+		prev     []byte
+		bitBuf   uint32
+		bitCount uint
+		codeBits uint = lzwMinCodeBits
+	)
+	reset := func() {
+		table = table[:0]
+		for i := 0; i < lzwClearCode; i++ {
+			table = append(table, []byte{byte(i)})
+		}
+		table = append(table, nil, nil)
+		codeBits = lzwMinCodeBits
+		prev = nil
+	}
+	reset()
+
+	for pos := 0; ; {
+		for bitCount < codeBits && pos < len(data) {
+			bitBuf = bitBuf<<8 | uint32(data[pos])
+			bitCount += 8
+			pos++
+		}
+		if bitCount < codeBits {
+			return out, nil
+		}
+		if len(out)%(1<<16) == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		code := int(bitBuf>>(bitCount-codeBits)) & (1<<codeBits - 1)
+		bitCount -= codeBits
+
+		switch {
+		case code == lzwClearCode:
+			reset()
+			continue
+		case code == lzwEODCode:
+			return out, nil
+		}
+
+		var entry []byte
+		switch {
+		case code < len(table) && table[code] != nil:
+			entry = table[code]
+		case code == len(table) && prev != nil:
+			entry = append(append([]byte(nil), prev...), prev[0])
+		default:
+			return nil, fmt.Errorf("LZW 编码无效: %d", code)
+		}
+		out = append(out, entry...)
+		if err := checkStreamSize(len(out)); err != nil {
+			return nil, err
+		}
+		if prev != nil && len(table) < 1<<lzwMaxCodeBits {
+			table = append(table, append(append([]byte(nil), prev...), entry[0]))
+		}
+		prev = entry
+		if len(table)+earlyChange >= 1<<codeBits && codeBits < lzwMaxCodeBits {
+			codeBits++
+		}
+	}
+}
+
+func decodeASCIIHexFilter(_ context.Context, data []byte, _ pdfDict) ([]byte, error) {
+	out := make([]byte, 0, len(data)/2)
+	var hi byte
+	half := false
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if isWhitespace(c) {
+			continue
+		}
+		v, ok := hexValue(c)
+		if !ok {
+			return nil, fmt.Errorf("ASCIIHex 数据含无效字符 %q", c)
+		}
+		if half {
+			out = append(out, hi<<4|v)
+		} else {
+			hi = v
+		}
+		half = !half
+	}
+	if half {
+		out = append(out, hi<<4)
+	}
+	return out, nil
+}
+
+func decodeASCII85Filter(_ context.Context, data []byte, _ pdfDict) ([]byte, error) {
+	src := make([]byte, 0, len(data))
+	for _, c := range data {
+		if !isWhitespace(c) {
+			src = append(src, c)
+		}
+	}
+	src = bytes.TrimPrefix(src, []byte("<~"))
+	if end := bytes.Index(src, []byte("~>")); end >= 0 {
+		src = src[:end]
+	}
+
+	out := make([]byte, 4*len(src))
+	n, _, err := ascii85.Decode(out, src, true)
+	if err != nil {
+		return nil, err
+	}
+	return out[:n], nil
+}
+
+func decodeRunLengthFilter(_ context.Context, data []byte, _ pdfDict) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(data); {
+		n := int(data[i])
+		i++
+		switch {
+		case n == runLengthEOD:
+			return out, nil
+		case n < runLengthEOD:
+			end := i + n + 1
+			if end > len(data) {
+				return nil, errors.New("RunLength 数据不完整")
+			}
+			out = append(out, data[i:end]...)
+			i = end
+		default:
+			if i >= len(data) {
+				return nil, errors.New("RunLength 数据不完整")
+			}
+			out = append(out, bytes.Repeat([]byte{data[i]}, 257-n)...)
+			i++
+		}
+		if err := checkStreamSize(len(out)); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
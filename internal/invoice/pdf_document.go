@@ -0,0 +1,201 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	pdfNameType    pdfName = "Type"
+	pdfNameLength  pdfName = "Length"
+	pdfNameFilter  pdfName = "Filter"
+	pdfNameRoot    pdfName = "Root"
+	pdfNamePrev    pdfName = "Prev"
+	pdfNameNames   pdfName = "Names"
+	pdfNameKids    pdfName = "Kids"
+	pdfNameEF      pdfName = "EF"
+	pdfNameF       pdfName = "F"
+	pdfNameUF      pdfName = "UF"
+	pdfNameAF      pdfName = "AF"
+	pdfNameFlate   pdfName = "FlateDecode"
+	pdfNameEmbFile pdfName = "EmbeddedFile"
+
+	pdfNameEmbeddedFiles pdfName = "EmbeddedFiles"
+
+	pdfMaxResolveDepth = 32
+)
+
+type pdfDocument struct {
+	ctx        context.Context
+	data       []byte
+	xref       *xrefTable
+	scanned    map[int]int
+	compressed map[int]xrefEntry
+	objStms    map[int]*objectStream
+	cache      map[int]pdfObject
+	resolving  map[int]bool
+	crypt      *securityHandler
+}
+
+func openPDFDocument(ctx context.Context, data []byte) *pdfDocument {
+	d := &pdfDocument{
+		ctx:       ctx,
+		data:      data,
+		objStms:   make(map[int]*objectStream),
+		cache:     make(map[int]pdfObject),
+		resolving: make(map[int]bool),
+	}
+	if err := d.loadXref(); err != nil {
+		d.xref = nil
+	}
+	return d
+}
+
+func (d *pdfDocument) trailer() pdfDict {
+	if d.xref != nil {
+		return d.xref.trailer
+	}
+	return d.scannedTrailer()
+}
+
+func (d *pdfDocument) objectNumbers() []int {
+	seen := make(map[int]bool)
+	var nums []int
+	if d.xref != nil {
+		for num, e := range d.xref.entries {
+			if e.kind != xrefFree {
+				seen[num] = true
+				nums = append(nums, num)
+			}
+		}
+	}
+	for num := range d.scanObjects() {
+		if !seen[num] {
+			seen[num] = true
+			nums = append(nums, num)
+		}
+	}
+	for num := range d.scanCompressedObjects() {
+		if !seen[num] {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+func (d *pdfDocument) getObject(ref objectRef) (pdfObject, error) {
+	if obj, ok := d.cache[ref.objNum]; ok {
+		return obj, nil
+	}
+	if d.resolving[ref.objNum] {
+		return nil, fmt.Errorf("PDF 对象 %d 存在循环引用", ref.objNum)
+	}
+	d.resolving[ref.objNum] = true
+	defer delete(d.resolving, ref.objNum)
+
+	obj, err := d.loadObject(ref)
+	if err != nil {
+		return nil, err
+	}
+	d.cache[ref.objNum] = obj
+	return obj, nil
+}
+
+func (d *pdfDocument) loadObject(ref objectRef) (pdfObject, error) {
+	if d.xref != nil {
+		if e, ok := d.xref.entries[ref.objNum]; ok {
+			obj, err := d.loadEntry(ref.objNum, e)
+			if err == nil {
+				return obj, nil
+			}
+		}
+	}
+	if offset, ok := d.scanObjects()[ref.objNum]; ok {
+		return d.readObjectAt(offset, ref.objNum)
+	}
+	if e, ok := d.scanCompressedObjects()[ref.objNum]; ok {
+		return d.loadEntry(ref.objNum, e)
+	}
+	return nil, fmt.Errorf("未找到 PDF 对象 %d %d", ref.objNum, ref.genNum)
+}
+
+func (d *pdfDocument) loadEntry(num int, e xrefEntry) (pdfObject, error) {
+	switch e.kind {
+	case xrefFree:
+		return nil, nil
+	case xrefCompressed:
+		return d.readCompressedObject(num, e)
+	default:
+		return d.readObjectAt(e.offset, num)
+	}
+}
+
+func (d *pdfDocument) readObjectAt(offset int, wantNum int) (pdfObject, error) {
+	if offset < 0 || offset >= len(d.data) {
+		return nil, fmt.Errorf("PDF 对象偏移越界: %d", offset)
+	}
+	p := newPDFParser(d.data, offset)
+	p.resolveInt = d.resolveInt
+	ref, obj, err := p.parseIndirectObject()
+	if err != nil {
+		return nil, err
+	}
+	if ref.objNum != wantNum {
+		return nil, fmt.Errorf("偏移 %d 处是对象 %d，而不是 %d", offset, ref.objNum, wantNum)
+	}
+	return d.decryptObject(ref, obj)
+}
+
+func (d *pdfDocument) resolve(obj pdfObject) (pdfObject, error) {
+	for depth := 0; depth < pdfMaxResolveDepth; depth++ {
+		ref, ok := obj.(objectRef)
+		if !ok {
+			return obj, nil
+		}
+		next, err := d.getObject(ref)
+		if err != nil {
+			return nil, err
+		}
+		obj = next
+	}
+	return nil, errors.New("PDF 间接引用层级过深")
+}
+
+func (d *pdfDocument) resolveInt(ref objectRef) (int, error) {
+	obj, err := d.resolve(ref)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := obj.(int)
+	if !ok {
+		return 0, errors.New("间接 /Length 对象不是整数")
+	}
+	return n, nil
+}
+
+func (d *pdfDocument) resolveDict(obj pdfObject) (pdfDict, bool) {
+	v, err := d.resolve(obj)
+	if err != nil {
+		return nil, false
+	}
+	switch dict := v.(type) {
+	case pdfDict:
+		return dict, true
+	case pdfStream:
+		return dict.dict, true
+	default:
+		return nil, false
+	}
+}
+
+func (d *pdfDocument) resolveArray(obj pdfObject) (pdfArray, bool) {
+	v, err := d.resolve(obj)
+	if err != nil {
+		return nil, false
+	}
+	arr, ok := v.(pdfArray)
+	return arr, ok
+}
@@ -1,88 +1,91 @@
 package invoice
 
 import (
-	"bytes"
+	"context"
 	"errors"
-	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 const (
-	pdfKeyType   = "/Type"
-	pdfNameEmbed = "/EmbeddedFile"
-	pdfKeyLength = "/Length"
-
 	pdfKeywordStream = "stream"
+
+	pdfMaxNameTreeDepth = 32
 )
 
-func extractEmbeddedXbrl(pdfBytes []byte) ([]byte, error) {
-	typePositions := findEmbeddedFileTypePositions(pdfBytes)
-	if len(typePositions) == 0 {
-		return nil, errors.New("未在 PDF 中找到可解析的 XBRL 数据")
-	}
+var xbrlFileExts = []string{".xbrl", ".xml"}
 
-	var firstErr error
-	for _, typePos := range typePositions {
-		xbrl, err := extractEmbeddedFileXbrlAt(pdfBytes, typePos)
-		if err != nil {
-			firstErr = firstNonNil(firstErr, err)
-			continue
-		}
-		return xbrl, nil
-	}
-	if firstErr != nil {
-		return nil, firstErr
-	}
-	return nil, errNoEmbeddedXbrl
+type embeddedFile struct {
+	name   string
+	ref    objectRef
+	stream pdfStream
 }
 
-func findEmbeddedFileTypePositions(pdfBytes []byte) []int {
-	var positions []int
-	searchFrom := 0
-	for {
-		idx := bytes.Index(pdfBytes[searchFrom:], []byte(pdfKeyType))
-		if idx < 0 {
-			return positions
-		}
-		abs := searchFrom + idx
-		nameStart := skipWhitespace(pdfBytes, abs+len(pdfKeyType))
-		if nameStart < len(pdfBytes) && pdfBytes[nameStart] == '/' {
-			nameEnd := readNameEnd(pdfBytes, nameStart+1)
-			if bytes.Equal(pdfBytes[nameStart:nameEnd], []byte(pdfNameEmbed)) {
-				positions = append(positions, abs)
-			}
-		}
-		searchFrom = abs + len(pdfKeyType)
+func extractEmbeddedXbrl(ctx context.Context, pdfBytes []byte, passwords []string) ([]byte, error) {
+	doc := openPDFDocument(ctx, pdfBytes)
+	if err := doc.unlock(passwords); err != nil {
+		return nil, err
 	}
+	xbrl, _, err := doc.findEmbeddedXbrl(ctx, nil)
+	return xbrl, err
 }
 
-func extractEmbeddedFileXbrlAt(pdfBytes []byte, typePos int) ([]byte, error) {
-	_, contentStart, err := findObjectContentStartBefore(pdfBytes, typePos)
-	if err != nil {
-		return nil, err
+func (d *pdfDocument) findEmbeddedXbrl(ctx context.Context, onAttempt func(embeddedFile, error)) ([]byte, embeddedFile, error) {
+	seen := make(map[int]bool)
+	found := false
+	var firstErr error
+	try := func(files []embeddedFile) ([]byte, embeddedFile, bool, error) {
+		for _, f := range files {
+			if err := ctx.Err(); err != nil {
+				return nil, embeddedFile{}, false, err
+			}
+			if f.ref.objNum > 0 {
+				if seen[f.ref.objNum] {
+					continue
+				}
+				seen[f.ref.objNum] = true
+			}
+			found = true
+			xbrl, err := d.decodeEmbeddedXbrl(f.stream)
+			if onAttempt != nil {
+				onAttempt(f, err)
+			}
+			if err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return nil, embeddedFile{}, false, ctxErr
+				}
+				firstErr = firstNonNil(firstErr, err)
+				continue
+			}
+			return xbrl, f, true, nil
+		}
+		return nil, embeddedFile{}, false, nil
 	}
 
-	streamKw, streamDataStart, err := findStreamDataStart(pdfBytes, typePos)
-	if err != nil {
-		return nil, err
-	}
-	if streamKw <= contentStart {
-		return nil, errors.New("PDF stream 位置异常")
+	for _, collect := range []func(context.Context) []embeddedFile{d.catalogEmbeddedFiles, d.scannedEmbeddedFiles} {
+		xbrl, f, ok, err := try(collect(ctx))
+		if err != nil {
+			return nil, embeddedFile{}, err
+		}
+		if ok {
+			return xbrl, f, nil
+		}
 	}
-
-	spec, err := parseLengthSpec(pdfBytes[contentStart:streamKw])
-	if err != nil {
-		return nil, err
+	if err := ctx.Err(); err != nil {
+		return nil, embeddedFile{}, err
 	}
-	length, err := resolveLength(pdfBytes, spec)
-	if err != nil {
-		return nil, err
+	if !found {
+		return nil, embeddedFile{}, errors.New("未在 PDF 中找到可解析的 XBRL 数据")
 	}
-	streamEnd := streamDataStart + length
-	if streamEnd > len(pdfBytes) {
-		return nil, fmt.Errorf("embedded stream 越界: start=%d len=%d pdf=%d", streamDataStart, length, len(pdfBytes))
+	if firstErr != nil {
+		return nil, embeddedFile{}, firstErr
 	}
+	return nil, embeddedFile{}, errNoEmbeddedXbrl
+}
 
-	raw, err := decompressFlate(pdfBytes[streamDataStart:streamEnd])
+func (d *pdfDocument) decodeEmbeddedXbrl(s pdfStream) ([]byte, error) {
+	raw, err := d.decodeStream(s)
 	if err != nil {
 		return nil, err
 	}
@@ -92,52 +95,126 @@ func extractEmbeddedFileXbrlAt(pdfBytes []byte, typePos int) ([]byte, error) {
 	return nil, errNoEmbeddedXbrl
 }
 
-type lengthSpec struct {
-	direct     int
-	indirect   objectRef
-	isIndirect bool
-}
+func (d *pdfDocument) catalogEmbeddedFiles(ctx context.Context) []embeddedFile {
+	catalog, ok := d.resolveDict(d.trailer()[pdfNameRoot])
+	if !ok {
+		return nil
+	}
 
-type objectRef struct {
-	objNum int
-	genNum int
-}
+	var specs []pdfObject
+	if names, ok := d.resolveDict(catalog[pdfNameNames]); ok {
+		d.walkNameTree(ctx, names[pdfNameEmbeddedFiles], 0, make(map[int]bool), func(v pdfObject) {
+			specs = append(specs, v)
+		})
+	}
+	if af, ok := d.resolveArray(catalog[pdfNameAF]); ok {
+		specs = append(specs, af...)
+	}
 
-func parseLengthSpec(dictBytes []byte) (lengthSpec, error) {
-	idx := findNameKey(dictBytes, pdfKeyLength)
-	if idx < 0 {
-		return lengthSpec{}, errors.New("未找到 EmbeddedFile 的 /Length")
+	var files []embeddedFile
+	for _, spec := range specs {
+		if f, ok := d.fileSpecStream(spec); ok {
+			files = append(files, f)
+		}
 	}
+	sort.SliceStable(files, func(a, b int) bool {
+		return isXbrlFileName(files[a].name) && !isXbrlFileName(files[b].name)
+	})
+	return files
+}
 
-	pos := skipWhitespace(dictBytes, idx+len(pdfKeyLength))
-	if pos >= len(dictBytes) {
-		return lengthSpec{}, errors.New("EmbeddedFile /Length 缺少值")
+func (d *pdfDocument) walkNameTree(ctx context.Context, node pdfObject, depth int, visited map[int]bool, visit func(pdfObject)) {
+	if depth > pdfMaxNameTreeDepth || ctx.Err() != nil {
+		return
 	}
+	if ref, ok := node.(objectRef); ok {
+		if visited[ref.objNum] {
+			return
+		}
+		visited[ref.objNum] = true
+	}
+	dict, ok := d.resolveDict(node)
+	if !ok {
+		return
+	}
+	if names, ok := d.resolveArray(dict[pdfNameNames]); ok {
+		for i := 1; i < len(names); i += 2 {
+			visit(names[i])
+		}
+	}
+	if kids, ok := d.resolveArray(dict[pdfNameKids]); ok {
+		for _, kid := range kids {
+			d.walkNameTree(ctx, kid, depth+1, visited, visit)
+		}
+	}
+}
 
-	objNum, next, ok := parseIntAt(dictBytes, pos)
+func (d *pdfDocument) fileSpecStream(spec pdfObject) (embeddedFile, bool) {
+	dict, ok := d.resolveDict(spec)
+	if !ok {
+		return embeddedFile{}, false
+	}
+	ef, ok := d.resolveDict(dict[pdfNameEF])
 	if !ok {
-		return lengthSpec{}, errors.New("EmbeddedFile /Length 值不是整数")
-	}
-
-	genPos := skipWhitespace(dictBytes, next)
-	genNum, next2, ok2 := parseIntAt(dictBytes, genPos)
-	if ok2 {
-		rPos := skipWhitespace(dictBytes, next2)
-		if rPos < len(dictBytes) && dictBytes[rPos] == 'R' {
-			return lengthSpec{
-				indirect:   objectRef{objNum: objNum, genNum: genNum},
-				isIndirect: true,
-			}, nil
+		return embeddedFile{}, false
+	}
+	name := pdfTextString(dict[pdfNameUF])
+	if name == "" {
+		name = pdfTextString(dict[pdfNameF])
+	}
+	for _, key := range []pdfName{pdfNameUF, pdfNameF} {
+		obj, ok := ef[key]
+		if !ok {
+			continue
+		}
+		v, err := d.resolve(obj)
+		if err != nil {
+			continue
+		}
+		if s, ok := v.(pdfStream); ok {
+			ref, _ := obj.(objectRef)
+			return embeddedFile{name: name, ref: ref, stream: s}, true
 		}
 	}
+	return embeddedFile{}, false
+}
 
-	return lengthSpec{direct: objNum}, nil
+func (d *pdfDocument) scannedEmbeddedFiles(ctx context.Context) []embeddedFile {
+	var files []embeddedFile
+	for _, num := range d.objectNumbers() {
+		if ctx.Err() != nil {
+			return files
+		}
+		ref := objectRef{objNum: num}
+		obj, err := d.getObject(ref)
+		if err != nil {
+			continue
+		}
+		s, ok := obj.(pdfStream)
+		if ok && s.dict[pdfNameType] == pdfNameEmbFile {
+			files = append(files, embeddedFile{ref: ref, stream: s})
+		}
+	}
+	return files
 }
 
-func resolveLength(pdfBytes []byte, spec lengthSpec) (int, error) {
-	if !spec.isIndirect {
-		return spec.direct, nil
+func isXbrlFileName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range xbrlFileExts {
+		if ext == e {
+			return true
+		}
 	}
-	return resolveIndirectIntObject(pdfBytes, spec.indirect)
+	return false
 }
 
+func pdfTextString(obj pdfObject) string {
+	s, ok := obj.(pdfString)
+	if !ok {
+		return ""
+	}
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		return utf16BEString(s[2:])
+	}
+	return string(s)
+}
@@ -0,0 +1,291 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	pdfNameEncrypt         pdfName = "Encrypt"
+	pdfNameID              pdfName = "ID"
+	pdfNameStandard        pdfName = "Standard"
+	pdfNameV               pdfName = "V"
+	pdfNameR               pdfName = "R"
+	pdfNameO               pdfName = "O"
+	pdfNameU               pdfName = "U"
+	pdfNameOE              pdfName = "OE"
+	pdfNameUE              pdfName = "UE"
+	pdfNameP               pdfName = "P"
+	pdfNameCF              pdfName = "CF"
+	pdfNameCFM             pdfName = "CFM"
+	pdfNameStmF            pdfName = "StmF"
+	pdfNameStrF            pdfName = "StrF"
+	pdfNameEFF             pdfName = "EFF"
+	pdfNameEncryptMetadata pdfName = "EncryptMetadata"
+	pdfNameIdentity        pdfName = "Identity"
+	pdfNameCrypt           pdfName = "Crypt"
+	pdfNameName            pdfName = "Name"
+	pdfNameMetadata        pdfName = "Metadata"
+
+	pdfDefaultKeyBits = 40
+)
+
+var ErrPDFPasswordRequired = errors.New("PDF 已加密，需要密码")
+
+type cryptMethod int
+
+const (
+	cryptIdentity cryptMethod = iota
+	cryptRC4
+	cryptAESV2
+	cryptAESV3
+)
+
+var cryptMethodsByCFM = map[pdfName]cryptMethod{
+	"None":  cryptIdentity,
+	"V2":    cryptRC4,
+	"AESV2": cryptAESV2,
+	"AESV3": cryptAESV3,
+}
+
+type securityHandler struct {
+	v, r            int
+	keyLen          int
+	o, u, oe, ue    []byte
+	p               int32
+	id0             []byte
+	encryptMetadata bool
+
+	filters    map[pdfName]cryptMethod
+	stmF, strF cryptMethod
+	eff        cryptMethod
+
+	encryptNum int
+	key        []byte
+}
+
+func (d *pdfDocument) unlock(passwords []string) error {
+	trailer := d.trailer()
+	encObj, ok := trailer[pdfNameEncrypt]
+	if !ok {
+		return nil
+	}
+	dict, ok := d.resolveDict(encObj)
+	if !ok {
+		return errors.New("PDF /Encrypt 字典无效")
+	}
+	h, err := d.newSecurityHandler(dict)
+	if err != nil {
+		return err
+	}
+	if ref, ok := encObj.(objectRef); ok {
+		h.encryptNum = ref.objNum
+	}
+	if ids, ok := d.resolveArray(trailer[pdfNameID]); ok && len(ids) > 0 {
+		if id0, ok := ids[0].(pdfString); ok {
+			h.id0 = id0
+		}
+	}
+
+	for _, pw := range append([]string{""}, passwords...) {
+		if key, ok := h.authenticate([]byte(pw)); ok {
+			h.key = key
+			break
+		}
+	}
+	if h.key == nil {
+		return ErrPDFPasswordRequired
+	}
+
+	d.crypt = h
+	d.cache = make(map[int]pdfObject)
+	d.objStms = make(map[int]*objectStream)
+	return nil
+}
+
+func (d *pdfDocument) newSecurityHandler(dict pdfDict) (*securityHandler, error) {
+	if filter, _ := dict[pdfNameFilter].(pdfName); filter != pdfNameStandard {
+		return nil, fmt.Errorf("不支持的 PDF 加密方式: /%s", filter)
+	}
+	h := &securityHandler{encryptMetadata: true}
+	h.v, _ = dict[pdfNameV].(int)
+	h.r, _ = dict[pdfNameR].(int)
+	p, _ := dict[pdfNameP].(int)
+	h.p = int32(p)
+	h.o, _ = dict[pdfNameO].(pdfString)
+	h.u, _ = dict[pdfNameU].(pdfString)
+	h.oe, _ = dict[pdfNameOE].(pdfString)
+	h.ue, _ = dict[pdfNameUE].(pdfString)
+	if em, ok := dict[pdfNameEncryptMetadata].(bool); ok {
+		h.encryptMetadata = em
+	}
+	if h.r < 2 || h.r > 6 {
+		return nil, fmt.Errorf("不支持的 PDF 加密修订版本: R%d", h.r)
+	}
+	if len(h.o) < 32 || len(h.u) < 32 {
+		return nil, errors.New("PDF /Encrypt 缺少 /O 或 /U")
+	}
+
+	bits := pdfDefaultKeyBits
+	if n, ok := dict[pdfNameLength].(int); ok && n > 0 {
+		bits = n
+	}
+	h.keyLen = bits / 8
+
+	switch h.v {
+	case 1, 2:
+		h.stmF, h.strF, h.eff = cryptRC4, cryptRC4, cryptRC4
+		if h.v == 1 {
+			h.keyLen = pdfDefaultKeyBits / 8
+		}
+	case 4, 5:
+		if err := h.loadCryptFilters(d, dict); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不支持的 PDF 加密算法: V%d", h.v)
+	}
+
+	switch {
+	case h.r >= 5:
+		h.keyLen = 32
+		if len(h.o) < 48 || len(h.u) < 48 || len(h.oe) < 32 || len(h.ue) < 32 {
+			return nil, errors.New("PDF /Encrypt 缺少 /OE 或 /UE")
+		}
+	case h.stmF == cryptAESV2 || h.strF == cryptAESV2:
+		h.keyLen = 16
+	}
+	if h.keyLen < 5 || h.keyLen > 16 && h.r < 5 {
+		return nil, fmt.Errorf("PDF 加密密钥长度无效: %d 位", h.keyLen*8)
+	}
+	return h, nil
+}
+
+func (h *securityHandler) loadCryptFilters(d *pdfDocument, dict pdfDict) error {
+	h.filters = map[pdfName]cryptMethod{pdfNameIdentity: cryptIdentity}
+	if cf, ok := d.resolveDict(dict[pdfNameCF]); ok {
+		for name, v := range cf {
+			fd, ok := d.resolveDict(v)
+			if !ok {
+				continue
+			}
+			cfm, _ := fd[pdfNameCFM].(pdfName)
+			method, ok := cryptMethodsByCFM[cfm]
+			if !ok {
+				return fmt.Errorf("不支持的 PDF 加密过滤器: /%s", cfm)
+			}
+			h.filters[name] = method
+		}
+	}
+	lookup := func(key pdfName, def cryptMethod) (cryptMethod, error) {
+		name, ok := dict[key].(pdfName)
+		if !ok {
+			return def, nil
+		}
+		method, ok := h.filters[name]
+		if !ok {
+			return 0, fmt.Errorf("PDF /Encrypt 引用了未定义的加密过滤器: /%s", name)
+		}
+		return method, nil
+	}
+	var err error
+	if h.stmF, err = lookup(pdfNameStmF, cryptIdentity); err != nil {
+		return err
+	}
+	if h.strF, err = lookup(pdfNameStrF, cryptIdentity); err != nil {
+		return err
+	}
+	h.eff, err = lookup(pdfNameEFF, h.stmF)
+	return err
+}
+
+func (d *pdfDocument) decryptObject(ref objectRef, obj pdfObject) (pdfObject, error) {
+	h := d.crypt
+	if h == nil || ref.objNum == h.encryptNum {
+		return obj, nil
+	}
+	switch v := obj.(type) {
+	case pdfString:
+		return h.decrypt(h.strF, ref, v)
+	case pdfArray:
+		for i, item := range v {
+			dec, err := d.decryptObject(ref, item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = dec
+		}
+		return v, nil
+	case pdfDict:
+		for k, item := range v {
+			dec, err := d.decryptObject(ref, item)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = dec
+		}
+		return v, nil
+	case pdfStream:
+		return d.decryptStream(ref, v)
+	default:
+		return obj, nil
+	}
+}
+
+func (d *pdfDocument) decryptStream(ref objectRef, s pdfStream) (pdfObject, error) {
+	h := d.crypt
+	typ := s.dict[pdfNameType]
+	if typ == pdfNameXRef {
+		return s, nil
+	}
+	if _, err := d.decryptObject(ref, s.dict); err != nil {
+		return nil, err
+	}
+
+	method := h.stmF
+	switch {
+	case typ == pdfNameEmbFile:
+		method = h.eff
+	case typ == pdfNameMetadata && !h.encryptMetadata:
+		method = cryptIdentity
+	}
+	if name, ok := d.cryptFilterName(s.dict); ok {
+		m, ok := h.filters[name]
+		if !ok {
+			return nil, fmt.Errorf("stream 引用了未定义的加密过滤器: /%s", name)
+		}
+		method = m
+	}
+
+	raw, err := h.decrypt(method, ref, s.raw)
+	if err != nil {
+		return nil, fmt.Errorf("解密 PDF 对象 %d 失败: %w", ref.objNum, err)
+	}
+	s.raw = raw
+	return s, nil
+}
+
+func (d *pdfDocument) cryptFilterName(dict pdfDict) (pdfName, bool) {
+	filters, parms, err := d.streamFilters(dict)
+	if err != nil || len(filters) == 0 || filters[0] != pdfNameCrypt {
+		return "", false
+	}
+	if len(filters) == 1 {
+		delete(dict, pdfNameFilter)
+		delete(dict, pdfNameDecodeParms)
+	} else {
+		rest := make(pdfArray, 0, len(filters)-1)
+		restParms := make(pdfArray, 0, len(filters)-1)
+		for i := 1; i < len(filters); i++ {
+			rest = append(rest, filters[i])
+			restParms = append(restParms, parms[i])
+		}
+		dict[pdfNameFilter] = rest
+		dict[pdfNameDecodeParms] = restParms
+	}
+	name := pdfNameIdentity
+	if n, ok := parms[0][pdfNameName].(pdfName); ok {
+		name = n
+	}
+	return name, true
+}
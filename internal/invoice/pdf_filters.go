@@ -0,0 +1,104 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	pdfNameDecodeParms pdfName = "DecodeParms"
+
+	pdfMaxStreamSize = 32 << 20
+)
+
+var errStreamTooLarge = fmt.Errorf("解码后的 stream 超过 %d MB 上限", pdfMaxStreamSize>>20)
+
+type streamDecoder func(ctx context.Context, data []byte, parms pdfDict) ([]byte, error)
+
+var streamDecoders = map[pdfName]streamDecoder{
+	"FlateDecode":     decodeFlateFilter,
+	"Fl":              decodeFlateFilter,
+	"LZWDecode":       decodeLZWFilter,
+	"LZW":             decodeLZWFilter,
+	"ASCIIHexDecode":  decodeASCIIHexFilter,
+	"AHx":             decodeASCIIHexFilter,
+	"ASCII85Decode":   decodeASCII85Filter,
+	"A85":             decodeASCII85Filter,
+	"RunLengthDecode": decodeRunLengthFilter,
+	"RL":              decodeRunLengthFilter,
+}
+
+func (d *pdfDocument) decodeStream(s pdfStream) ([]byte, error) {
+	filters, parms, err := d.streamFilters(s.dict)
+	if err != nil {
+		return nil, err
+	}
+	data := s.raw
+	for i, f := range filters {
+		if err := d.ctx.Err(); err != nil {
+			return nil, err
+		}
+		decode, ok := streamDecoders[f]
+		if !ok {
+			return nil, fmt.Errorf("不支持的 stream 过滤器: /%s", f)
+		}
+		if data, err = decode(d.ctx, data, parms[i]); err != nil {
+			return nil, fmt.Errorf("/%s 解码失败: %w", f, err)
+		}
+		if err := checkStreamSize(len(data)); err != nil {
+			return nil, fmt.Errorf("/%s 解码失败: %w", f, err)
+		}
+	}
+	return data, nil
+}
+
+func checkStreamSize(n int) error {
+	if n > pdfMaxStreamSize {
+		return errStreamTooLarge
+	}
+	return nil
+}
+
+func (d *pdfDocument) streamFilters(dict pdfDict) ([]pdfName, []pdfDict, error) {
+	filterObj, err := d.resolve(dict[pdfNameFilter])
+	if err != nil {
+		return nil, nil, err
+	}
+	var filters []pdfName
+	switch f := filterObj.(type) {
+	case nil:
+		return nil, nil, nil
+	case pdfName:
+		filters = []pdfName{f}
+	case pdfArray:
+		for _, item := range f {
+			v, err := d.resolve(item)
+			if err != nil {
+				return nil, nil, err
+			}
+			name, ok := v.(pdfName)
+			if !ok {
+				return nil, nil, errors.New("stream /Filter 格式无效")
+			}
+			filters = append(filters, name)
+		}
+	default:
+		return nil, nil, errors.New("stream /Filter 格式无效")
+	}
+
+	parms := make([]pdfDict, len(filters))
+	parmsObj, err := d.resolve(dict[pdfNameDecodeParms])
+	if err != nil {
+		return nil, nil, err
+	}
+	switch p := parmsObj.(type) {
+	case pdfDict:
+		parms[0] = p
+	case pdfArray:
+		for i := 0; i < len(p) && i < len(parms); i++ {
+			parms[i], _ = d.resolveDict(p[i])
+		}
+	}
+	return filters, parms, nil
+}
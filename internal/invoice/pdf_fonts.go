@@ -0,0 +1,83 @@
+package invoice
+
+import "strings"
+
+const (
+	pdfNameFont      pdfName = "Font"
+	pdfNameToUnicode pdfName = "ToUnicode"
+	pdfNameEncoding  pdfName = "Encoding"
+	pdfNameSubtype   pdfName = "Subtype"
+	pdfNameType0     pdfName = "Type0"
+
+	fontWideRuneEm   = 1.0
+	fontNarrowRuneEm = 0.5
+)
+
+type pdfFont struct {
+	cmap *toUnicodeCMap
+	ucs2 bool
+	wide bool
+}
+
+func (d *pdfDocument) loadFont(resources pdfDict, name pdfName, cache map[objectRef]*pdfFont) *pdfFont {
+	fonts, ok := d.resolveDict(resources[pdfNameFont])
+	if !ok {
+		return &pdfFont{}
+	}
+	obj := fonts[name]
+	f := &pdfFont{}
+	if ref, ok := obj.(objectRef); ok {
+		if cached, ok := cache[ref]; ok {
+			return cached
+		}
+		cache[ref] = f
+	}
+	dict, ok := d.resolveDict(obj)
+	if !ok {
+		return f
+	}
+
+	f.wide = dict[pdfNameSubtype] == pdfNameType0
+	if enc, ok := dict[pdfNameEncoding].(pdfName); ok {
+		f.ucs2 = strings.Contains(string(enc), "UCS2") || strings.Contains(string(enc), "UTF16")
+	}
+	if v, err := d.resolve(dict[pdfNameToUnicode]); err == nil {
+		if s, ok := v.(pdfStream); ok {
+			if data, err := d.decodeStream(s); err == nil {
+				f.cmap = parseToUnicodeCMap(data)
+			}
+		}
+	}
+	return f
+}
+
+func (f *pdfFont) decode(b []byte) string {
+	switch {
+	case f.cmap != nil:
+		return f.cmap.decode(b)
+	case f.ucs2:
+		return utf16BEString(b)
+	case f.wide:
+		return ""
+	default:
+		out := make([]rune, 0, len(b))
+		for _, c := range b {
+			if c >= 0x20 {
+				out = append(out, rune(c))
+			}
+		}
+		return string(out)
+	}
+}
+
+func estimateTextWidth(text string) float64 {
+	w := 0.0
+	for _, r := range text {
+		if r >= 0x2E80 {
+			w += fontWideRuneEm
+		} else {
+			w += fontNarrowRuneEm
+		}
+	}
+	return w
+}
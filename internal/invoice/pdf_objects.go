@@ -0,0 +1,464 @@
+package invoice
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	pdfMaxNesting = 64
+
+	pdfKeywordObj       = "obj"
+	pdfKeywordEndStream = "endstream"
+	pdfKeywordRef       = "R"
+)
+
+type pdfObject interface{}
+
+type pdfName string
+
+type pdfString []byte
+
+type pdfArray []pdfObject
+
+type pdfDict map[pdfName]pdfObject
+
+type pdfKeyword string
+
+type pdfStream struct {
+	dict pdfDict
+	raw  []byte
+}
+
+type objectRef struct {
+	objNum int
+	genNum int
+}
+
+type pdfTokenKind int
+
+const (
+	tokEOF pdfTokenKind = iota
+	tokInt
+	tokReal
+	tokName
+	tokString
+	tokArrayOpen
+	tokArrayClose
+	tokDictOpen
+	tokDictClose
+	tokKeyword
+)
+
+type pdfToken struct {
+	kind pdfTokenKind
+	i    int
+	f    float64
+	s    []byte
+}
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) skipSpaceAndComments() {
+	for l.pos < len(l.data) {
+		switch {
+		case isWhitespace(l.data[l.pos]):
+			l.pos++
+		case l.data[l.pos] == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *pdfLexer) next() (pdfToken, error) {
+	l.skipSpaceAndComments()
+	if l.pos >= len(l.data) {
+		return pdfToken{kind: tokEOF}, nil
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '[':
+		l.pos++
+		return pdfToken{kind: tokArrayOpen}, nil
+	case c == ']':
+		l.pos++
+		return pdfToken{kind: tokArrayClose}, nil
+	case c == '<' && l.peekByte(1) == '<':
+		l.pos += 2
+		return pdfToken{kind: tokDictOpen}, nil
+	case c == '>' && l.peekByte(1) == '>':
+		l.pos += 2
+		return pdfToken{kind: tokDictClose}, nil
+	case c == '<':
+		return l.readHexString()
+	case c == '(':
+		return l.readLiteralString()
+	case c == '/':
+		return l.readName(), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.readNumber()
+	case isDelimiter(c):
+		return pdfToken{}, fmt.Errorf("PDF 语法错误：位置 %d 出现意外字符 %q", l.pos, c)
+	default:
+		start := l.pos
+		for l.pos < len(l.data) && !isDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfToken{kind: tokKeyword, s: l.data[start:l.pos]}, nil
+	}
+}
+
+func (l *pdfLexer) peekByte(off int) byte {
+	if l.pos+off < len(l.data) {
+		return l.data[l.pos+off]
+	}
+	return 0
+}
+
+func (l *pdfLexer) readNumber() (pdfToken, error) {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.data) && (l.data[l.pos] == '.' || (l.data[l.pos] >= '0' && l.data[l.pos] <= '9')) {
+		l.pos++
+	}
+	text := string(l.data[start:l.pos])
+	if v, err := strconv.Atoi(text); err == nil {
+		return pdfToken{kind: tokInt, i: v}, nil
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		if text == "-" || text == "+" || text == "." {
+			return pdfToken{kind: tokReal}, nil
+		}
+		return pdfToken{}, fmt.Errorf("PDF 数字无效: %q", text)
+	}
+	return pdfToken{kind: tokReal, f: v}, nil
+}
+
+func (l *pdfLexer) readName() pdfToken {
+	l.pos++
+	var out []byte
+	for l.pos < len(l.data) && !isDelimiter(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				out = append(out, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		out = append(out, c)
+		l.pos++
+	}
+	return pdfToken{kind: tokName, s: out}
+}
+
+func (l *pdfLexer) readHexString() (pdfToken, error) {
+	l.pos++
+	var out []byte
+	var hi byte
+	half := false
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			if half {
+				out = append(out, hi<<4)
+			}
+			return pdfToken{kind: tokString, s: out}, nil
+		}
+		if isWhitespace(c) {
+			continue
+		}
+		v, ok := hexValue(c)
+		if !ok {
+			return pdfToken{}, fmt.Errorf("PDF 十六进制字符串无效字符 %q", c)
+		}
+		if half {
+			out = append(out, hi<<4|v)
+		} else {
+			hi = v
+		}
+		half = !half
+	}
+	return pdfToken{}, errors.New("PDF 十六进制字符串未结束")
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	default:
+		return 0, false
+	}
+}
+
+func (l *pdfLexer) readLiteralString() (pdfToken, error) {
+	l.pos++
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfToken{kind: tokString, s: out}, nil
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				continue
+			}
+			out = l.readEscape(out)
+			continue
+		}
+		out = append(out, c)
+	}
+	return pdfToken{}, errors.New("PDF 字符串未结束")
+}
+
+func (l *pdfLexer) readEscape(out []byte) []byte {
+	c := l.data[l.pos]
+	l.pos++
+	switch c {
+	case 'n':
+		return append(out, '\n')
+	case 'r':
+		return append(out, '\r')
+	case 't':
+		return append(out, '\t')
+	case 'b':
+		return append(out, '\b')
+	case 'f':
+		return append(out, '\f')
+	case '\r':
+		if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+			l.pos++
+		}
+		return out
+	case '\n':
+		return out
+	}
+	if c >= '0' && c <= '7' {
+		v := int(c - '0')
+		for n := 1; n < 3 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; n++ {
+			v = v*8 + int(l.data[l.pos]-'0')
+			l.pos++
+		}
+		return append(out, byte(v))
+	}
+	return append(out, c)
+}
+
+type pdfParser struct {
+	lex        pdfLexer
+	resolveInt func(objectRef) (int, error)
+}
+
+func newPDFParser(data []byte, pos int) *pdfParser {
+	return &pdfParser{lex: pdfLexer{data: data, pos: pos}}
+}
+
+func (p *pdfParser) parseObject() (pdfObject, error) {
+	return p.parseObjectDepth(0)
+}
+
+func (p *pdfParser) parseObjectDepth(depth int) (pdfObject, error) {
+	if depth > pdfMaxNesting {
+		return nil, errors.New("PDF 对象嵌套过深")
+	}
+	tok, err := p.lex.next()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.kind {
+	case tokEOF:
+		return nil, errors.New("PDF 对象意外结束")
+	case tokInt:
+		if ref, ok := p.tryRef(tok.i); ok {
+			return ref, nil
+		}
+		return tok.i, nil
+	case tokReal:
+		return tok.f, nil
+	case tokName:
+		return pdfName(tok.s), nil
+	case tokString:
+		return pdfString(tok.s), nil
+	case tokArrayOpen:
+		return p.parseArray(depth)
+	case tokDictOpen:
+		return p.parseDict(depth)
+	case tokKeyword:
+		switch string(tok.s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return pdfKeyword(tok.s), nil
+	default:
+		return nil, fmt.Errorf("PDF 语法错误：位置 %d 出现意外的结束符", p.lex.pos)
+	}
+}
+
+func (p *pdfParser) tryRef(objNum int) (objectRef, bool) {
+	save := p.lex.pos
+	gen, err := p.lex.next()
+	if err == nil && gen.kind == tokInt {
+		r, err := p.lex.next()
+		if err == nil && r.kind == tokKeyword && string(r.s) == pdfKeywordRef {
+			return objectRef{objNum: objNum, genNum: gen.i}, true
+		}
+	}
+	p.lex.pos = save
+	return objectRef{}, false
+}
+
+func (p *pdfParser) parseArray(depth int) (pdfArray, error) {
+	arr := pdfArray{}
+	for {
+		save := p.lex.pos
+		tok, err := p.lex.next()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case tokArrayClose:
+			return arr, nil
+		case tokEOF:
+			return nil, errors.New("PDF 数组未结束")
+		}
+		p.lex.pos = save
+		obj, err := p.parseObjectDepth(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, obj)
+	}
+}
+
+func (p *pdfParser) parseDict(depth int) (pdfDict, error) {
+	dict := pdfDict{}
+	for {
+		tok, err := p.lex.next()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case tokDictClose:
+			return dict, nil
+		case tokName:
+		default:
+			return nil, fmt.Errorf("PDF 字典键不是名称（位置 %d）", p.lex.pos)
+		}
+		val, err := p.parseObjectDepth(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		dict[pdfName(tok.s)] = val
+	}
+}
+
+func (p *pdfParser) parseIndirectObject() (objectRef, pdfObject, error) {
+	num, err := p.lex.next()
+	if err != nil || num.kind != tokInt {
+		return objectRef{}, nil, errors.New("未找到 PDF 对象头")
+	}
+	gen, err := p.lex.next()
+	if err != nil || gen.kind != tokInt {
+		return objectRef{}, nil, errors.New("未找到 PDF 对象头")
+	}
+	kw, err := p.lex.next()
+	if err != nil || kw.kind != tokKeyword || string(kw.s) != pdfKeywordObj {
+		return objectRef{}, nil, errors.New("未找到 PDF 对象头")
+	}
+	ref := objectRef{objNum: num.i, genNum: gen.i}
+
+	obj, err := p.parseObject()
+	if err != nil {
+		return ref, nil, err
+	}
+	dict, ok := obj.(pdfDict)
+	if !ok {
+		return ref, obj, nil
+	}
+	save := p.lex.pos
+	tok, err := p.lex.next()
+	if err != nil || tok.kind != tokKeyword || string(tok.s) != pdfKeywordStream {
+		p.lex.pos = save
+		return ref, dict, nil
+	}
+	raw, err := p.readStreamData(dict)
+	if err != nil {
+		return ref, nil, err
+	}
+	return ref, pdfStream{dict: dict, raw: raw}, nil
+}
+
+func (p *pdfParser) readStreamData(dict pdfDict) ([]byte, error) {
+	data := p.lex.data
+	start, err := parseStreamDataStart(data, p.lex.pos)
+	if err != nil {
+		return nil, err
+	}
+	if length, ok := p.streamLength(dict); ok && length >= 0 && length <= len(data)-start {
+		end := start + length
+		if isKeywordAt(data, skipWhitespace(data, end), []byte(pdfKeywordEndStream)) {
+			p.lex.pos = skipWhitespace(data, end) + len(pdfKeywordEndStream)
+			return data[start:end], nil
+		}
+	}
+
+	idx := bytes.Index(data[start:], []byte(pdfKeywordEndStream))
+	if idx < 0 {
+		return nil, errors.New("PDF stream 缺少 endstream")
+	}
+	end := start + idx
+	p.lex.pos = end + len(pdfKeywordEndStream)
+	return trimStreamEOL(data[start:end]), nil
+}
+
+func (p *pdfParser) streamLength(dict pdfDict) (int, bool) {
+	switch v := dict[pdfNameLength].(type) {
+	case int:
+		return v, true
+	case objectRef:
+		if p.resolveInt == nil {
+			return 0, false
+		}
+		n, err := p.resolveInt(v)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func trimStreamEOL(b []byte) []byte {
+	if bytes.HasSuffix(b, []byte("\r\n")) {
+		return b[:len(b)-2]
+	}
+	if bytes.HasSuffix(b, []byte("\n")) || bytes.HasSuffix(b, []byte("\r")) {
+		return b[:len(b)-1]
+	}
+	return b
+}
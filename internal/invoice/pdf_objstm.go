@@ -0,0 +1,123 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	pdfNameObjStm pdfName = "ObjStm"
+	pdfNameN      pdfName = "N"
+	pdfNameFirst  pdfName = "First"
+)
+
+type objectStream struct {
+	data    []byte
+	nums    []int
+	offsets []int
+}
+
+func (d *pdfDocument) readCompressedObject(num int, e xrefEntry) (pdfObject, error) {
+	stm, err := d.objectStream(e.stream)
+	if err != nil {
+		return nil, err
+	}
+	idx := e.index
+	if idx < 0 || idx >= len(stm.nums) || stm.nums[idx] != num {
+		idx = -1
+		for i, n := range stm.nums {
+			if n == num {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("对象流 %d 中未找到对象 %d", e.stream, num)
+		}
+	}
+	p := newPDFParser(stm.data, stm.offsets[idx])
+	return p.parseObject()
+}
+
+func (d *pdfDocument) objectStream(num int) (*objectStream, error) {
+	if stm, ok := d.objStms[num]; ok {
+		if stm == nil {
+			return nil, fmt.Errorf("对象流 %d 无效", num)
+		}
+		return stm, nil
+	}
+	d.objStms[num] = nil
+
+	obj, err := d.getObject(objectRef{objNum: num})
+	if err != nil {
+		return nil, err
+	}
+	s, ok := obj.(pdfStream)
+	if !ok || s.dict[pdfNameType] != pdfNameObjStm {
+		return nil, fmt.Errorf("对象 %d 不是对象流", num)
+	}
+	stm, err := d.parseObjectStream(s)
+	if err != nil {
+		return nil, err
+	}
+	d.objStms[num] = stm
+	return stm, nil
+}
+
+func (d *pdfDocument) parseObjectStream(s pdfStream) (*objectStream, error) {
+	n, ok1 := s.dict[pdfNameN].(int)
+	first, ok2 := s.dict[pdfNameFirst].(int)
+	if !ok1 || !ok2 || n < 0 || first < 0 {
+		return nil, errors.New("对象流缺少 /N 或 /First")
+	}
+	data, err := d.decodeStream(s)
+	if err != nil {
+		return nil, fmt.Errorf("解码对象流失败: %w", err)
+	}
+	if first > len(data) {
+		return nil, errors.New("对象流 /First 越界")
+	}
+
+	stm := &objectStream{data: data}
+	lex := pdfLexer{data: data[:first]}
+	for i := 0; i < n; i++ {
+		numTok, err1 := lex.next()
+		offTok, err2 := lex.next()
+		if err1 != nil || err2 != nil || numTok.kind != tokInt || offTok.kind != tokInt {
+			return nil, errors.New("对象流头部格式无效")
+		}
+		if numTok.i < 0 || offTok.i < 0 || first+offTok.i >= len(data) {
+			return nil, errors.New("对象流内偏移越界")
+		}
+		stm.nums = append(stm.nums, numTok.i)
+		stm.offsets = append(stm.offsets, first+offTok.i)
+	}
+	return stm, nil
+}
+
+func (d *pdfDocument) scanCompressedObjects() map[int]xrefEntry {
+	if d.compressed != nil {
+		return d.compressed
+	}
+	d.compressed = make(map[int]xrefEntry)
+	scanned := d.scanObjects()
+	nums := make([]int, 0, len(scanned))
+	for num := range scanned {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(a, b int) bool { return scanned[nums[a]] < scanned[nums[b]] })
+	for _, num := range nums {
+		if d.ctx.Err() != nil {
+			break
+		}
+		stm, err := d.objectStream(num)
+		if err != nil {
+			continue
+		}
+		for i, n := range stm.nums {
+			d.compressed[n] = xrefEntry{kind: xrefCompressed, stream: num, index: i}
+		}
+	}
+	return d.compressed
+}
@@ -0,0 +1,100 @@
+package invoice
+
+const (
+	pdfNamePages     pdfName = "Pages"
+	pdfNamePage      pdfName = "Page"
+	pdfNameResources pdfName = "Resources"
+	pdfNameContents  pdfName = "Contents"
+
+	pdfMaxPageTreeDepth = 32
+	pdfMaxPages         = 64
+)
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+func (d *pdfDocument) pages() []pdfPage {
+	var pages []pdfPage
+	if catalog, ok := d.resolveDict(d.trailer()[pdfNameRoot]); ok {
+		d.walkPageTree(catalog[pdfNamePages], nil, 0, make(map[int]bool), &pages)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	for _, num := range d.objectNumbers() {
+		if d.ctx.Err() != nil || len(pages) >= pdfMaxPages {
+			break
+		}
+		obj, err := d.getObject(objectRef{objNum: num})
+		if err != nil {
+			continue
+		}
+		if dict, ok := obj.(pdfDict); ok && dict[pdfNameType] == pdfNamePage {
+			res, _ := d.resolveDict(dict[pdfNameResources])
+			pages = append(pages, pdfPage{dict: dict, resources: res})
+		}
+	}
+	return pages
+}
+
+func (d *pdfDocument) walkPageTree(node pdfObject, inherited pdfDict, depth int, visited map[int]bool, pages *[]pdfPage) {
+	if depth > pdfMaxPageTreeDepth || len(*pages) >= pdfMaxPages || d.ctx.Err() != nil {
+		return
+	}
+	if ref, ok := node.(objectRef); ok {
+		if visited[ref.objNum] {
+			return
+		}
+		visited[ref.objNum] = true
+	}
+	dict, ok := d.resolveDict(node)
+	if !ok {
+		return
+	}
+	res := inherited
+	if own, ok := d.resolveDict(dict[pdfNameResources]); ok {
+		res = own
+	}
+	if kids, ok := d.resolveArray(dict[pdfNameKids]); ok && dict[pdfNameType] != pdfNamePage {
+		for _, kid := range kids {
+			d.walkPageTree(kid, res, depth+1, visited, pages)
+		}
+		return
+	}
+	*pages = append(*pages, pdfPage{dict: dict, resources: res})
+}
+
+func (d *pdfDocument) pageContent(page pdfPage) []byte {
+	v, err := d.resolve(page.dict[pdfNameContents])
+	if err != nil {
+		return nil
+	}
+	var streams []pdfObject
+	switch c := v.(type) {
+	case pdfStream:
+		streams = []pdfObject{c}
+	case pdfArray:
+		streams = c
+	}
+
+	var out []byte
+	for _, s := range streams {
+		obj, err := d.resolve(s)
+		if err != nil {
+			continue
+		}
+		stm, ok := obj.(pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := d.decodeStream(stm)
+		if err != nil {
+			continue
+		}
+		out = append(append(out, data...), '\n')
+	}
+	return out
+}
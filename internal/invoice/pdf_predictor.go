@@ -0,0 +1,171 @@
+package invoice
+
+import "fmt"
+
+const (
+	predictorNone     = 1
+	predictorTIFF     = 2
+	predictorPNGFirst = 10
+
+	pngFilterNone  = 0
+	pngFilterSub   = 1
+	pngFilterUp    = 2
+	pngFilterAvg   = 3
+	pngFilterPaeth = 4
+
+	pdfNamePredictor        pdfName = "Predictor"
+	pdfNameColors           pdfName = "Colors"
+	pdfNameBitsPerComponent pdfName = "BitsPerComponent"
+	pdfNameColumns          pdfName = "Columns"
+
+	predictorMaxColors = 32
+	predictorMaxBPC    = 16
+)
+
+type predictorParams struct {
+	predictor int
+	colors    int
+	bpc       int
+	columns   int
+}
+
+func parsePredictorParams(parms pdfDict) (predictorParams, error) {
+	p := predictorParams{predictor: predictorNone, colors: 1, bpc: 8, columns: 1}
+	if v, ok := parms[pdfNamePredictor].(int); ok {
+		p.predictor = v
+	}
+	if v, ok := parms[pdfNameColors].(int); ok && v > 0 {
+		p.colors = v
+	}
+	if v, ok := parms[pdfNameBitsPerComponent].(int); ok && v > 0 {
+		p.bpc = v
+	}
+	if v, ok := parms[pdfNameColumns].(int); ok && v > 0 {
+		p.columns = v
+	}
+	if p.colors > predictorMaxColors || p.bpc > predictorMaxBPC || p.columns > pdfMaxStreamSize*8 {
+		return predictorParams{}, p.invalid()
+	}
+	return p, nil
+}
+
+func applyPredictor(data []byte, parms pdfDict) ([]byte, error) {
+	p, err := parsePredictorParams(parms)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.predictor <= predictorNone || len(data) == 0:
+		return data, nil
+	case p.predictor == predictorTIFF:
+		return undoTIFFPredictor(data, p)
+	case p.predictor >= predictorPNGFirst:
+		return undoPNGPredictor(data, p)
+	default:
+		return nil, fmt.Errorf("不支持的预测器 /Predictor %d", p.predictor)
+	}
+}
+
+func (p predictorParams) rowBytes(dataLen int) (int, error) {
+	n := (int64(p.colors)*int64(p.bpc)*int64(p.columns) + 7) / 8
+	if n <= 0 || n > int64(dataLen) {
+		return 0, p.invalid()
+	}
+	return int(n), nil
+}
+
+func (p predictorParams) invalid() error {
+	return fmt.Errorf("预测器参数无效: /Colors %d /BitsPerComponent %d /Columns %d", p.colors, p.bpc, p.columns)
+}
+
+func (p predictorParams) pixelBytes() int {
+	n := (p.colors*p.bpc + 7) / 8
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func undoPNGPredictor(data []byte, p predictorParams) ([]byte, error) {
+	rowLen, err := p.rowBytes(len(data))
+	if err != nil {
+		return nil, err
+	}
+	bpp := p.pixelBytes()
+	out := make([]byte, 0, len(data)/(rowLen+1)*rowLen)
+	prev := make([]byte, rowLen)
+	for pos := 0; pos < len(data); pos += rowLen + 1 {
+		end := pos + rowLen + 1
+		if end > len(data) {
+			end = len(data)
+		}
+		filter := data[pos]
+		row := make([]byte, rowLen)
+		copy(row, data[pos+1:end])
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up := prev[i]
+			switch filter {
+			case pngFilterNone:
+			case pngFilterSub:
+				row[i] += left
+			case pngFilterUp:
+				row[i] += up
+			case pngFilterAvg:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case pngFilterPaeth:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("未知 PNG 预测类型 %d", filter)
+			}
+		}
+		out = append(out, row[:end-pos-1]...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func undoTIFFPredictor(data []byte, p predictorParams) ([]byte, error) {
+	if p.bpc != 8 {
+		return nil, fmt.Errorf("TIFF 预测器暂不支持 %d 位分量", p.bpc)
+	}
+	rowLen, err := p.rowBytes(len(data))
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte(nil), data...)
+	for rowStart := 0; rowStart < len(out); rowStart += rowLen {
+		rowEnd := rowStart + rowLen
+		if rowEnd > len(out) {
+			rowEnd = len(out)
+		}
+		for i := rowStart + p.colors; i < rowEnd; i++ {
+			out[i] += out[i-p.colors]
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,156 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	lineMergeFactor = 0.5
+	wordGapFactor   = 0.8
+	issueDateLabel  = "开票日期"
+)
+
+var (
+	reTextIssueDate  = regexp.MustCompile(issueDateLabel + `[:：]?\s*(\d{4})\s*[年\-/.]\s*(\d{1,2})\s*[月\-/.]\s*(\d{1,2})`)
+	reTextDate       = regexp.MustCompile(`(\d{4})\s*年\s*(\d{1,2})\s*月\s*(\d{1,2})\s*日`)
+	reTextDepartTime = regexp.MustCompile(`(\d{1,2}:\d{2})\s*开`)
+	reTextTrain      = regexp.MustCompile(`(?:^|[^A-Za-z0-9])([GDCZTKYSL]\d{1,4})(?:[^A-Za-z0-9]|$)`)
+	reTextStation    = regexp.MustCompile(`(\p{Han}{1,10}?)站`)
+	reTextHan        = regexp.MustCompile(`\p{Han}+`)
+	reTextSeat       = regexp.MustCompile(`(\d{1,2})\s*车\s*(\d{1,3}[A-F]?)\s*号`)
+	reTextSeatClass  = regexp.MustCompile(`商务座|特等座|一等座|二等座|高级软卧|软卧|硬卧|动卧|一等卧|二等卧|软座|硬座|无座`)
+	reTextFare       = regexp.MustCompile(`[￥¥]\s*(\d+(?:\.\d{1,2})?)`)
+	reTextInvoiceNo  = regexp.MustCompile(`发票号码[:：]?\s*(\d{8,20})`)
+	reTextTicketNo   = regexp.MustCompile(`电子客票号[:：]?\s*([0-9A-Za-z]{10,30})`)
+)
+
+func extractInvoiceInfoFromTextLayer(ctx context.Context, pdfBytes []byte, passwords []string) (InvoiceInfo, error) {
+	doc := openPDFDocument(ctx, pdfBytes)
+	if err := doc.unlock(passwords); err != nil {
+		return InvoiceInfo{}, err
+	}
+	var lines []string
+	for _, page := range doc.pages() {
+		lines = append(lines, layoutTextLines(doc.pageTextRuns(page))...)
+	}
+	if err := ctx.Err(); err != nil {
+		return InvoiceInfo{}, err
+	}
+	if len(lines) == 0 {
+		return InvoiceInfo{}, errors.New("PDF 没有可识别的文本层")
+	}
+
+	info := parseTicketText(lines)
+	info.LowConfidence = true
+	return finishInvoiceInfo(info)
+}
+
+func layoutTextLines(runs []textRun) []string {
+	sort.SliceStable(runs, func(a, b int) bool {
+		return runs[a].y > runs[b].y
+	})
+
+	var groups [][]textRun
+	for _, r := range runs {
+		if n := len(groups); n > 0 {
+			head := groups[n-1][0]
+			if math.Abs(head.y-r.y) <= math.Max(head.size, r.size)*lineMergeFactor {
+				groups[n-1] = append(groups[n-1], r)
+				continue
+			}
+		}
+		groups = append(groups, []textRun{r})
+	}
+
+	lines := make([]string, 0, len(groups))
+	for _, g := range groups {
+		sort.SliceStable(g, func(a, b int) bool { return g[a].x < g[b].x })
+		var sb strings.Builder
+		for i, r := range g {
+			if i > 0 {
+				prev := g[i-1]
+				if r.x-(prev.x+prev.width) > math.Max(prev.size, r.size)*wordGapFactor {
+					sb.WriteByte(' ')
+				}
+			}
+			sb.WriteString(r.text)
+		}
+		if line := strings.TrimSpace(sb.String()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func parseTicketText(lines []string) InvoiceInfo {
+	info := InvoiceInfo{}
+	for _, line := range lines {
+		if m := reTextIssueDate.FindStringSubmatch(line); m != nil && info.DateOfIssue == "" {
+			info.DateOfIssue = formatTextDate(m[1], m[2], m[3])
+		} else if m := reTextDate.FindStringSubmatch(line); m != nil && info.TravelDate == "" && !strings.Contains(line, issueDateLabel) {
+			info.TravelDate = formatTextDate(m[1], m[2], m[3])
+		}
+		setFromMatch(&info.DepartureTime, reTextDepartTime, line)
+		setFromMatch(&info.InvoiceNumber, reTextInvoiceNo, line)
+		setFromMatch(&info.ElectronicTicketNumber, reTextTicketNo, line)
+		setFromMatch(&info.Fare, reTextFare, line)
+		if m := reTextSeat.FindStringSubmatch(line); m != nil && info.Carriage == "" {
+			info.Carriage, info.SeatNumber = m[1], m[2]
+		}
+		if info.SeatClass == "" {
+			info.SeatClass = reTextSeatClass.FindString(line)
+		}
+	}
+	parseTicketRoute(&info, lines)
+	return info
+}
+
+func parseTicketRoute(info *InvoiceInfo, lines []string) {
+	for _, line := range lines {
+		if m := reTextTrain.FindStringSubmatchIndex(line); m != nil && info.TrainNumber == "" {
+			info.TrainNumber = line[m[2]:m[3]]
+			if stations := reTextStation.FindAllStringSubmatch(line, -1); len(stations) >= 2 {
+				info.DepartureStation, info.DestinationStation = stations[0][1], stations[1][1]
+				return
+			}
+			before := reTextHan.FindAllString(line[:m[2]], -1)
+			after := reTextHan.FindAllString(line[m[3]:], -1)
+			if len(before) > 0 && len(after) > 0 {
+				info.DepartureStation, info.DestinationStation = before[len(before)-1], after[0]
+				return
+			}
+		}
+	}
+
+	var stations []string
+	for _, line := range lines {
+		for _, m := range reTextStation.FindAllStringSubmatch(line, -1) {
+			stations = append(stations, m[1])
+		}
+	}
+	if len(stations) >= 2 {
+		info.DepartureStation, info.DestinationStation = stations[0], stations[1]
+	}
+}
+
+func setFromMatch(dst *string, re *regexp.Regexp, line string) {
+	if *dst != "" {
+		return
+	}
+	if m := re.FindStringSubmatch(line); m != nil {
+		*dst = m[1]
+	}
+}
+
+func formatTextDate(y, m, d string) string {
+	month, _ := strconv.Atoi(m)
+	day, _ := strconv.Atoi(d)
+	return fmt.Sprintf("%s-%02d-%02d", y, month, day)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/flate"
 	"compress/zlib"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -15,10 +16,21 @@ const (
 )
 
 func ExtractXbrlFromPDFBytes(pdfBytes []byte) ([]byte, error) {
+	return ExtractXbrlFromPDFBytesContext(context.Background(), pdfBytes)
+}
+
+func ExtractXbrlFromPDFBytesContext(ctx context.Context, pdfBytes []byte) ([]byte, error) {
+	return ExtractXbrlFromPDFBytesWithPasswords(ctx, pdfBytes, nil)
+}
+
+func ExtractXbrlFromPDFBytesWithPasswords(ctx context.Context, pdfBytes []byte, passwords []string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if xbrl, ok := extractPlainXbrl(pdfBytes); ok {
 		return xbrl, nil
 	}
-	return extractEmbeddedXbrl(pdfBytes)
+	return extractEmbeddedXbrl(ctx, pdfBytes, passwords)
 }
 
 func extractPlainXbrl(pdfBytes []byte) ([]byte, bool) {
@@ -35,32 +47,65 @@ func extractPlainXbrl(pdfBytes []byte) ([]byte, bool) {
 	return pdfBytes[start:end], true
 }
 
-func decompressFlate(data []byte) ([]byte, error) {
-	if out, err := decompressZlib(data); err == nil {
+func decompressFlate(ctx context.Context, data []byte) ([]byte, error) {
+	out, err := decompressZlib(ctx, data)
+	if err == nil {
 		return out, nil
 	}
-	return decompressRawDeflate(data)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if errors.Is(err, errStreamTooLarge) {
+		return nil, err
+	}
+	return decompressRawDeflate(ctx, data)
+}
+
+func readAllLimited(ctx context.Context, r io.Reader) ([]byte, error) {
+	out, err := io.ReadAll(io.LimitReader(contextReader{ctx: ctx, r: r}, pdfMaxStreamSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStreamSize(len(out)); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func decompressZlib(data []byte) ([]byte, error) {
+func decompressZlib(ctx context.Context, data []byte) ([]byte, error) {
 	r, err := zlib.NewReader(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("zlib 解压失败: %w", err)
 	}
 	defer r.Close()
-	return io.ReadAll(r)
+	return readAllLimited(ctx, r)
 }
 
-func decompressRawDeflate(data []byte) ([]byte, error) {
+func decompressRawDeflate(ctx context.Context, data []byte) ([]byte, error) {
 	r := flate.NewReader(bytes.NewReader(data))
 	defer r.Close()
-	out, err := io.ReadAll(r)
+	out, err := readAllLimited(ctx, r)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	if err != nil {
 		return nil, fmt.Errorf("deflate 解压失败: %w", err)
 	}
 	return out, nil
 }
 
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
 func firstNonNil(a, b error) error {
 	if a != nil {
 		return a
@@ -69,4 +114,3 @@ func firstNonNil(a, b error) error {
 }
 
 var errNoEmbeddedXbrl = errors.New("未在 PDF EmbeddedFile 中找到 XBRL")
-
@@ -0,0 +1,231 @@
+package invoice
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	pdfKeywordStartXref = "startxref"
+	pdfKeywordXref      = "xref"
+	pdfKeywordTrailer   = "trailer"
+
+	xrefTailSearchLen = 4096
+	xrefMaxSections   = 256
+)
+
+type xrefKind int
+
+const (
+	xrefFree xrefKind = iota
+	xrefInUse
+	xrefCompressed
+)
+
+type xrefEntry struct {
+	kind   xrefKind
+	offset int
+	gen    int
+	stream int
+	index  int
+}
+
+type xrefTable struct {
+	entries map[int]xrefEntry
+	trailer pdfDict
+}
+
+func newXrefTable() *xrefTable {
+	return &xrefTable{entries: make(map[int]xrefEntry)}
+}
+
+func (t *xrefTable) addIfAbsent(num int, e xrefEntry) {
+	if _, ok := t.entries[num]; ok {
+		return
+	}
+	t.entries[num] = e
+}
+
+func (t *xrefTable) mergeTrailer(trailer pdfDict) {
+	if t.trailer == nil {
+		t.trailer = pdfDict{}
+	}
+	for k, v := range trailer {
+		if _, ok := t.trailer[k]; !ok {
+			t.trailer[k] = v
+		}
+	}
+}
+
+func findStartXref(data []byte) (int, error) {
+	tailStart := len(data) - xrefTailSearchLen
+	if tailStart < 0 {
+		tailStart = 0
+	}
+	idx := bytes.LastIndex(data[tailStart:], []byte(pdfKeywordStartXref))
+	if idx < 0 {
+		return 0, errors.New("未找到 startxref")
+	}
+	pos := skipWhitespace(data, tailStart+idx+len(pdfKeywordStartXref))
+	offset, _, ok := parseIntAt(data, pos)
+	if !ok || offset >= len(data) {
+		return 0, errors.New("startxref 偏移无效")
+	}
+	return offset, nil
+}
+
+func (d *pdfDocument) loadXref() error {
+	offset, err := findStartXref(d.data)
+	if err != nil {
+		return err
+	}
+
+	table := newXrefTable()
+	visited := make(map[int]bool)
+	for sections := 0; ; sections++ {
+		if sections >= xrefMaxSections || visited[offset] {
+			return errors.New("xref /Prev 链存在循环")
+		}
+		visited[offset] = true
+
+		trailer, err := d.readXrefSection(table, offset)
+		if err != nil {
+			return err
+		}
+		table.mergeTrailer(trailer)
+
+		prev, ok := trailer[pdfNamePrev].(int)
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+	if _, ok := table.trailer[pdfNameRoot]; !ok {
+		return errors.New("PDF trailer 缺少 /Root")
+	}
+	d.xref = table
+	return nil
+}
+
+func (d *pdfDocument) readXrefSection(table *xrefTable, offset int) (pdfDict, error) {
+	if offset < 0 || offset >= len(d.data) {
+		return nil, fmt.Errorf("xref 偏移越界: %d", offset)
+	}
+	pos := skipWhitespace(d.data, offset)
+	if !isKeywordAt(d.data, pos, []byte(pdfKeywordXref)) {
+		return d.readXrefStream(table, offset)
+	}
+	trailer, err := d.readClassicXref(table, pos+len(pdfKeywordXref))
+	if err != nil {
+		return nil, err
+	}
+	if stmOffset, ok := trailer[pdfNameXRefStm].(int); ok {
+		if _, err := d.readXrefStream(table, stmOffset); err != nil {
+			return nil, err
+		}
+	}
+	return trailer, nil
+}
+
+func (d *pdfDocument) readClassicXref(table *xrefTable, pos int) (pdfDict, error) {
+	lex := pdfLexer{data: d.data, pos: pos}
+	for {
+		tok, err := lex.next()
+		if err != nil {
+			return nil, fmt.Errorf("解析 xref 表失败: %w", err)
+		}
+		if tok.kind == tokKeyword && string(tok.s) == pdfKeywordTrailer {
+			break
+		}
+		if tok.kind != tokInt {
+			return nil, errors.New("xref 表格式无效")
+		}
+		first := tok.i
+		count, err := lex.next()
+		if err != nil || count.kind != tokInt {
+			return nil, errors.New("xref 子段缺少对象数量")
+		}
+		for i := 0; i < count.i; i++ {
+			off, err1 := lex.next()
+			gen, err2 := lex.next()
+			kind, err3 := lex.next()
+			if err1 != nil || err2 != nil || err3 != nil || off.kind != tokInt || gen.kind != tokInt || kind.kind != tokKeyword {
+				return nil, errors.New("xref 条目格式无效")
+			}
+			e := xrefEntry{kind: xrefFree, offset: off.i, gen: gen.i}
+			if string(kind.s) == "n" {
+				e.kind = xrefInUse
+			}
+			table.addIfAbsent(first+i, e)
+		}
+	}
+
+	p := newPDFParser(d.data, lex.pos)
+	obj, err := p.parseObject()
+	if err != nil {
+		return nil, fmt.Errorf("解析 trailer 失败: %w", err)
+	}
+	trailer, ok := obj.(pdfDict)
+	if !ok {
+		return nil, errors.New("trailer 不是字典")
+	}
+	return trailer, nil
+}
+
+func (d *pdfDocument) scanObjects() map[int]int {
+	if d.scanned != nil {
+		return d.scanned
+	}
+	d.scanned = make(map[int]int)
+	kw := []byte(pdfKeywordObj)
+	for pos := 0; ; {
+		idx := bytes.Index(d.data[pos:], kw)
+		if idx < 0 {
+			break
+		}
+		abs := pos + idx
+		pos = abs + len(kw)
+		if !isKeywordAt(d.data, abs, kw) {
+			continue
+		}
+		lineStart := findLineStart(d.data, abs)
+		if !isObjectHeaderLine(d.data[lineStart : abs+len(kw)]) {
+			continue
+		}
+		num, _, _ := parseIntAt(d.data, skipWhitespace(d.data, lineStart))
+		d.scanned[num] = lineStart
+	}
+	return d.scanned
+}
+
+func (d *pdfDocument) scannedTrailer() pdfDict {
+	if idx := bytes.LastIndex(d.data, []byte(pdfKeywordTrailer)); idx >= 0 {
+		p := newPDFParser(d.data, idx+len(pdfKeywordTrailer))
+		if obj, err := p.parseObject(); err == nil {
+			if trailer, ok := obj.(pdfDict); ok {
+				if _, ok := trailer[pdfNameRoot]; ok {
+					return trailer
+				}
+			}
+		}
+	}
+
+	var trailer pdfDict
+	lastOffset := -1
+	for num, offset := range d.scanObjects() {
+		if offset < lastOffset {
+			continue
+		}
+		obj, err := d.getObject(objectRef{objNum: num})
+		if err != nil {
+			continue
+		}
+		if s, ok := obj.(pdfStream); ok && s.dict[pdfNameType] == pdfNameXRef {
+			if _, ok := s.dict[pdfNameRoot]; ok {
+				trailer, lastOffset = s.dict, offset
+			}
+		}
+	}
+	return trailer
+}
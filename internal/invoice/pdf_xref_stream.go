@@ -0,0 +1,122 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	pdfNameXRef    pdfName = "XRef"
+	pdfNameXRefStm pdfName = "XRefStm"
+	pdfNameW       pdfName = "W"
+	pdfNameIndex   pdfName = "Index"
+	pdfNameSize    pdfName = "Size"
+
+	xrefStreamFields  = 3
+	xrefMaxFieldWidth = 8
+)
+
+func (d *pdfDocument) readXrefStream(table *xrefTable, offset int) (pdfDict, error) {
+	if offset < 0 || offset >= len(d.data) {
+		return nil, fmt.Errorf("xref 偏移越界: %d", offset)
+	}
+	p := newPDFParser(d.data, offset)
+	p.resolveInt = d.resolveInt
+	_, obj, err := p.parseIndirectObject()
+	if err != nil {
+		return nil, fmt.Errorf("偏移 %d 处既不是 xref 表也不是 xref 流: %w", offset, err)
+	}
+	s, ok := obj.(pdfStream)
+	if !ok || s.dict[pdfNameType] != pdfNameXRef {
+		return nil, fmt.Errorf("偏移 %d 处不是 xref 流", offset)
+	}
+
+	widths, err := xrefFieldWidths(s.dict)
+	if err != nil {
+		return nil, err
+	}
+	index, err := xrefStreamIndex(s.dict)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.decodeStream(s)
+	if err != nil {
+		return nil, fmt.Errorf("解码 xref 流失败: %w", err)
+	}
+
+	rowLen := widths[0] + widths[1] + widths[2]
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		first, count := index[i], index[i+1]
+		for n := 0; n < count; n++ {
+			if pos+rowLen > len(data) {
+				return nil, errors.New("xref 流数据长度不足")
+			}
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+
+			kind := 1
+			if widths[0] > 0 {
+				kind = readBigEndian(row[:widths[0]])
+			}
+			f2 := readBigEndian(row[widths[0] : widths[0]+widths[1]])
+			f3 := readBigEndian(row[widths[0]+widths[1]:])
+			switch kind {
+			case 0:
+				table.addIfAbsent(first+n, xrefEntry{kind: xrefFree})
+			case 1:
+				table.addIfAbsent(first+n, xrefEntry{kind: xrefInUse, offset: f2, gen: f3})
+			case 2:
+				table.addIfAbsent(first+n, xrefEntry{kind: xrefCompressed, stream: f2, index: f3})
+			}
+		}
+	}
+	return s.dict, nil
+}
+
+func xrefFieldWidths(dict pdfDict) ([xrefStreamFields]int, error) {
+	var widths [xrefStreamFields]int
+	arr, ok := dict[pdfNameW].(pdfArray)
+	if !ok || len(arr) < xrefStreamFields {
+		return widths, errors.New("xref 流缺少 /W")
+	}
+	for i := range widths {
+		w, ok := arr[i].(int)
+		if !ok || w < 0 || w > xrefMaxFieldWidth {
+			return widths, errors.New("xref 流 /W 无效")
+		}
+		widths[i] = w
+	}
+	if widths[0]+widths[1]+widths[2] == 0 {
+		return widths, errors.New("xref 流 /W 无效")
+	}
+	return widths, nil
+}
+
+func xrefStreamIndex(dict pdfDict) ([]int, error) {
+	arr, ok := dict[pdfNameIndex].(pdfArray)
+	if !ok {
+		size, ok := dict[pdfNameSize].(int)
+		if !ok {
+			return nil, errors.New("xref 流缺少 /Size")
+		}
+		return []int{0, size}, nil
+	}
+	index := make([]int, 0, len(arr))
+	for _, v := range arr {
+		n, ok := v.(int)
+		if !ok || n < 0 {
+			return nil, errors.New("xref 流 /Index 无效")
+		}
+		index = append(index, n)
+	}
+	return index, nil
+}
+
+func readBigEndian(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
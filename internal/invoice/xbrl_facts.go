@@ -0,0 +1,273 @@
+package invoice
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	xbrlInstanceNS  = "http://www.xbrl.org/2003/instance"
+	xbrlLinkbaseNS  = "http://www.xbrl.org/2003/linkbase"
+	xbrlXlinkNS     = "http://www.w3.org/1999/xlink"
+	xbrlDimensionNS = "http://xbrl.org/2006/xbrldi"
+	xbrlSchemaNS    = "http://www.w3.org/2001/XMLSchema-instance"
+
+	xbrlContextTag = "context"
+	xbrlUnitTag    = "unit"
+	xbrlNilValue   = "true"
+)
+
+var ErrConflictingXbrlFacts = errors.New("XBRL 存在取值冲突的重复事实")
+
+var xbrlInfrastructureNS = map[string]bool{
+	xbrlInstanceNS:  true,
+	xbrlLinkbaseNS:  true,
+	xbrlXlinkNS:     true,
+	xbrlDimensionNS: true,
+	xbrlSchemaNS:    true,
+}
+
+type XbrlFact struct {
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	ContextRef string `json:"contextRef,omitempty"`
+	UnitRef    string `json:"unitRef,omitempty"`
+	Decimals   string `json:"decimals,omitempty"`
+	Nil        bool   `json:"nil,omitempty"`
+	Value      string `json:"value"`
+}
+
+type XbrlIdentifier struct {
+	Scheme string `json:"scheme,omitempty" xml:"scheme,attr"`
+	Value  string `json:"value,omitempty" xml:",chardata"`
+}
+
+type XbrlContext struct {
+	ID        string         `json:"id" xml:"id,attr"`
+	Entity    XbrlIdentifier `json:"entity" xml:"entity>identifier"`
+	Instant   string         `json:"instant,omitempty" xml:"period>instant"`
+	StartDate string         `json:"startDate,omitempty" xml:"period>startDate"`
+	EndDate   string         `json:"endDate,omitempty" xml:"period>endDate"`
+}
+
+type XbrlUnit struct {
+	ID       string   `json:"id" xml:"id,attr"`
+	Measures []string `json:"measures,omitempty" xml:"measure"`
+}
+
+type XbrlDocument struct {
+	Facts    []XbrlFact             `json:"facts"`
+	Contexts map[string]XbrlContext `json:"contexts,omitempty"`
+	Units    map[string]XbrlUnit    `json:"units,omitempty"`
+}
+
+type xbrlFactKey struct {
+	namespace, name, contextRef, unitRef string
+}
+
+type xbrlFrame struct {
+	start    xml.StartElement
+	text     strings.Builder
+	children bool
+}
+
+func ParseXbrlDocument(xbrlBytes []byte) (XbrlDocument, error) {
+	doc := XbrlDocument{Contexts: make(map[string]XbrlContext), Units: make(map[string]XbrlUnit)}
+	seen := make(map[xbrlFactKey]int)
+	dec := xml.NewDecoder(bytes.NewReader(xbrlBytes))
+	var stack []*xbrlFrame
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return doc, nil
+		}
+		if err != nil {
+			return XbrlDocument{}, fmt.Errorf("解析 XBRL 失败: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if n := len(stack); n > 0 {
+				stack[n-1].children = true
+			}
+			if isXbrlStructure(t.Name, xbrlContextTag) {
+				var c XbrlContext
+				if err := dec.DecodeElement(&c, &t); err != nil {
+					return XbrlDocument{}, fmt.Errorf("读取 XBRL context 失败: %w", err)
+				}
+				doc.Contexts[c.ID] = trimXbrlContext(c)
+				continue
+			}
+			if isXbrlStructure(t.Name, xbrlUnitTag) {
+				var u XbrlUnit
+				if err := dec.DecodeElement(&u, &t); err != nil {
+					return XbrlDocument{}, fmt.Errorf("读取 XBRL unit 失败: %w", err)
+				}
+				doc.Units[u.ID] = u
+				continue
+			}
+			stack = append(stack, &xbrlFrame{start: t.Copy()})
+		case xml.CharData:
+			if n := len(stack); n > 0 {
+				stack[n-1].text.Write(t)
+			}
+		case xml.EndElement:
+			n := len(stack)
+			if n == 0 {
+				continue
+			}
+			frame := stack[n-1]
+			stack = stack[:n-1]
+			if n == 1 || frame.children || xbrlInfrastructureNS[frame.start.Name.Space] {
+				continue
+			}
+			if err := doc.addFact(newXbrlFact(frame), seen); err != nil {
+				return XbrlDocument{}, err
+			}
+		}
+	}
+}
+
+func isXbrlStructure(name xml.Name, local string) bool {
+	return name.Local == local && (name.Space == xbrlInstanceNS || name.Space == "")
+}
+
+func trimXbrlContext(c XbrlContext) XbrlContext {
+	c.Entity.Value = strings.TrimSpace(c.Entity.Value)
+	c.Instant = strings.TrimSpace(c.Instant)
+	c.StartDate = strings.TrimSpace(c.StartDate)
+	c.EndDate = strings.TrimSpace(c.EndDate)
+	return c
+}
+
+func newXbrlFact(frame *xbrlFrame) XbrlFact {
+	f := XbrlFact{
+		Namespace: frame.start.Name.Space,
+		Name:      frame.start.Name.Local,
+		Value:     strings.TrimSpace(frame.text.String()),
+	}
+	for _, a := range frame.start.Attr {
+		switch {
+		case a.Name.Space == xbrlSchemaNS && a.Name.Local == "nil":
+			f.Nil = strings.TrimSpace(a.Value) == xbrlNilValue
+		case a.Name.Space != "":
+		case a.Name.Local == "contextRef":
+			f.ContextRef = a.Value
+		case a.Name.Local == "unitRef":
+			f.UnitRef = a.Value
+		case a.Name.Local == "decimals":
+			f.Decimals = a.Value
+		}
+	}
+	return f
+}
+
+func (d *XbrlDocument) addFact(f XbrlFact, seen map[xbrlFactKey]int) error {
+	key := xbrlFactKey{namespace: f.Namespace, name: f.Name, contextRef: f.ContextRef, unitRef: f.UnitRef}
+	if i, ok := seen[key]; ok {
+		if !sameXbrlValue(d.Facts[i], f) {
+			return fmt.Errorf("%w: %s（%q 与 %q）", ErrConflictingXbrlFacts, f.Name, d.Facts[i].Value, f.Value)
+		}
+		return nil
+	}
+	seen[key] = len(d.Facts)
+	d.Facts = append(d.Facts, f)
+	return nil
+}
+
+func sameXbrlValue(a, b XbrlFact) bool {
+	if a.Nil != b.Nil {
+		return false
+	}
+	if a.Value == b.Value {
+		return true
+	}
+	if a.UnitRef == "" {
+		return false
+	}
+	x, okA := ParseAmountCents(a.Value)
+	y, okB := ParseAmountCents(b.Value)
+	return okA && okB && x == y
+}
+
+func (d XbrlDocument) InvoiceInfo() (InvoiceInfo, error) {
+	info := InvoiceInfo{}
+	if err := d.applyTo(&info); err != nil {
+		return InvoiceInfo{}, err
+	}
+	return finishInvoiceInfo(info)
+}
+
+func (d XbrlDocument) applyTo(info *InvoiceInfo) error {
+	ns := d.invoiceNamespace()
+	contextRef := d.invoiceContext(ns)
+	chosen := make(map[*string]XbrlFact)
+	var order []*string
+	for _, f := range d.Facts {
+		field, ok := xbrlFieldTags[f.Name]
+		if !ok || f.Namespace != ns || f.Nil || f.Value == "" {
+			continue
+		}
+		dst := field(info)
+		prev, ok := chosen[dst]
+		if !ok {
+			chosen[dst] = f
+			order = append(order, dst)
+			continue
+		}
+		if !sameXbrlFieldValue(prev, f) {
+			return fmt.Errorf("%w: %s=%q（context %q）与 %s=%q（context %q）", ErrConflictingXbrlFacts, prev.Name, prev.Value, prev.ContextRef, f.Name, f.Value, f.ContextRef)
+		}
+		if prev.ContextRef != contextRef && f.ContextRef == contextRef {
+			chosen[dst] = f
+		}
+	}
+	for _, dst := range order {
+		if *dst == "" {
+			*dst = chosen[dst].Value
+		}
+	}
+	return nil
+}
+
+func (d XbrlDocument) invoiceNamespace() string {
+	return d.mostCommon(func(f XbrlFact) (string, bool) {
+		return f.Namespace, true
+	})
+}
+
+func (d XbrlDocument) invoiceContext(ns string) string {
+	return d.mostCommon(func(f XbrlFact) (string, bool) {
+		return f.ContextRef, f.Namespace == ns
+	})
+}
+
+func (d XbrlDocument) mostCommon(key func(f XbrlFact) (string, bool)) string {
+	counts := make(map[string]int)
+	for _, f := range d.Facts {
+		if _, ok := xbrlFieldTags[f.Name]; !ok || f.Nil || f.Value == "" {
+			continue
+		}
+		if k, ok := key(f); ok {
+			counts[k]++
+		}
+	}
+	best, bestCount := "", 0
+	for k, n := range counts {
+		if n > bestCount || (n == bestCount && k < best) {
+			best, bestCount = k, n
+		}
+	}
+	return best
+}
+
+func sameXbrlFieldValue(a, b XbrlFact) bool {
+	if a.UnitRef != "" && b.UnitRef != "" && a.UnitRef != b.UnitRef {
+		return false
+	}
+	return sameXbrlValue(a, b)
+}
@@ -0,0 +1,78 @@
+package invoice
+
+import "strings"
+
+const (
+	idMaskChar      = "*"
+	idKeepPrefixLen = 4
+	idKeepSuffixLen = 4
+)
+
+var xbrlFieldTags = map[string]func(info *InvoiceInfo) *string{
+	"TravelDate":         func(i *InvoiceInfo) *string { return &i.TravelDate },
+	"DateOfIssue":        func(i *InvoiceInfo) *string { return &i.DateOfIssue },
+	"DepartureStation":   func(i *InvoiceInfo) *string { return &i.DepartureStation },
+	"DestinationStation": func(i *InvoiceInfo) *string { return &i.DestinationStation },
+
+	"EInvoiceNumber": func(i *InvoiceInfo) *string { return &i.InvoiceNumber },
+	"InvoiceNumber":  func(i *InvoiceInfo) *string { return &i.InvoiceNumber },
+
+	"ElectronicTicketNumber": func(i *InvoiceInfo) *string { return &i.ElectronicTicketNumber },
+	"ETicketNumber":          func(i *InvoiceInfo) *string { return &i.ElectronicTicketNumber },
+
+	"TrainNumber": func(i *InvoiceInfo) *string { return &i.TrainNumber },
+
+	"SeatLevel": func(i *InvoiceInfo) *string { return &i.SeatClass },
+	"SeatClass": func(i *InvoiceInfo) *string { return &i.SeatClass },
+	"SeatType":  func(i *InvoiceInfo) *string { return &i.SeatClass },
+
+	"CarriageNumber": func(i *InvoiceInfo) *string { return &i.Carriage },
+	"Carriage":       func(i *InvoiceInfo) *string { return &i.Carriage },
+	"SeatNumber":     func(i *InvoiceInfo) *string { return &i.SeatNumber },
+
+	"DepartureTime": func(i *InvoiceInfo) *string { return &i.DepartureTime },
+
+	"NameOfPassenger": func(i *InvoiceInfo) *string { return &i.PassengerName },
+	"PassengerName":   func(i *InvoiceInfo) *string { return &i.PassengerName },
+
+	"IDNumberOfPassenger": func(i *InvoiceInfo) *string { return &i.PassengerIDMasked },
+	"PassengerIDNumber":   func(i *InvoiceInfo) *string { return &i.PassengerIDMasked },
+
+	"Fare":        func(i *InvoiceInfo) *string { return &i.Fare },
+	"TicketFare":  func(i *InvoiceInfo) *string { return &i.Fare },
+	"TicketPrice": func(i *InvoiceInfo) *string { return &i.Fare },
+
+	"TaxRate":   func(i *InvoiceInfo) *string { return &i.TaxRate },
+	"TaxAmount": func(i *InvoiceInfo) *string { return &i.TaxAmount },
+
+	"NameOfPurchaser": func(i *InvoiceInfo) *string { return &i.BuyerName },
+	"PurchaserName":   func(i *InvoiceInfo) *string { return &i.BuyerName },
+	"BuyerName":       func(i *InvoiceInfo) *string { return &i.BuyerName },
+
+	"TaxpayerIdentificationNumberOfPurchaser": func(i *InvoiceInfo) *string { return &i.BuyerTaxID },
+	"PurchaserTaxID": func(i *InvoiceInfo) *string { return &i.BuyerTaxID },
+	"BuyerTaxID":     func(i *InvoiceInfo) *string { return &i.BuyerTaxID },
+
+	"ItemName":                  func(i *InvoiceInfo) *string { return &i.ItemName },
+	"NameOfGoodsOrServices":     func(i *InvoiceInfo) *string { return &i.ItemName },
+	"NameOfGoodsOrTaxableItems": func(i *InvoiceInfo) *string { return &i.ItemName },
+
+	"Remarks": func(i *InvoiceInfo) *string { return &i.Remarks },
+	"Remark":  func(i *InvoiceInfo) *string { return &i.Remarks },
+
+	"OriginalInvoiceNumber":   func(i *InvoiceInfo) *string { return &i.OriginalInvoiceNumber },
+	"NumberOfOriginalInvoice": func(i *InvoiceInfo) *string { return &i.OriginalInvoiceNumber },
+}
+
+func maskIDNumber(id string) string {
+	s := strings.TrimSpace(id)
+	if s == "" || strings.Contains(s, idMaskChar) {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= idKeepPrefixLen+idKeepSuffixLen {
+		return strings.Repeat(idMaskChar, len(runes))
+	}
+	masked := strings.Repeat(idMaskChar, len(runes)-idKeepPrefixLen-idKeepSuffixLen)
+	return string(runes[:idKeepPrefixLen]) + masked + string(runes[len(runes)-idKeepSuffixLen:])
+}
@@ -1,51 +1,63 @@
 package invoice
 
 import (
-	"bytes"
-	"encoding/xml"
+	"context"
 	"errors"
 	"fmt"
-	"io"
+	"path/filepath"
 	"strings"
 )
 
 var errMissingRequiredField = errors.New("XBRL 缺少必要字段")
 
 func ExtractInvoiceInfoFromPDFBytes(pdfBytes []byte) (InvoiceInfo, error) {
-	xbrl, err := ExtractXbrlFromPDFBytes(pdfBytes)
+	return ExtractInvoiceInfoFromPDFBytesContext(context.Background(), pdfBytes)
+}
+
+func ExtractInvoiceInfoFromPDFBytesContext(ctx context.Context, pdfBytes []byte) (InvoiceInfo, error) {
+	return ExtractInvoiceInfoFromPDFBytesWithPasswords(ctx, pdfBytes, nil)
+}
+
+func ExtractInvoiceInfoFromPDFBytesWithPasswords(ctx context.Context, pdfBytes []byte, passwords []string) (InvoiceInfo, error) {
+	info, err := extractInvoiceInfoFromXbrl(ctx, pdfBytes, passwords)
+	if err == nil || ctx.Err() != nil || errors.Is(err, ErrPDFPasswordRequired) {
+		return info, err
+	}
+	info, textErr := extractInvoiceInfoFromTextLayer(ctx, pdfBytes, passwords)
+	if textErr != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return InvoiceInfo{}, ctxErr
+		}
+		return InvoiceInfo{}, fmt.Errorf("%w（文本层识别也失败: %v）", err, textErr)
+	}
+	return info, nil
+}
+
+func extractInvoiceInfoFromXbrl(ctx context.Context, pdfBytes []byte, passwords []string) (InvoiceInfo, error) {
+	xbrl, err := ExtractXbrlFromPDFBytesWithPasswords(ctx, pdfBytes, passwords)
 	if err != nil {
 		return InvoiceInfo{}, err
 	}
 	return ParseInvoiceInfoFromXbrl(xbrl)
 }
 
-func ParseInvoiceInfoFromXbrl(xbrlBytes []byte) (InvoiceInfo, error) {
-	dec := xml.NewDecoder(bytes.NewReader(xbrlBytes))
-	info := InvoiceInfo{}
-
-	for {
-		tok, err := dec.Token()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			return InvoiceInfo{}, fmt.Errorf("解析 XBRL 失败: %w", err)
-		}
-
-		start, ok := tok.(xml.StartElement)
-		if !ok {
-			continue
-		}
-		if !isWantedTag(start.Name.Local) {
-			continue
-		}
+func ExtractInvoiceInfo(ctx context.Context, fileName string, data []byte, passwords []string) (InvoiceInfo, error) {
+	if strings.EqualFold(filepath.Ext(fileName), OFDExt) {
+		return ExtractInvoiceInfoFromOFDBytes(ctx, data)
+	}
+	return ExtractInvoiceInfoFromPDFBytesWithPasswords(ctx, data, passwords)
+}
 
-		var text string
-		if err := dec.DecodeElement(&text, &start); err != nil {
-			return InvoiceInfo{}, fmt.Errorf("读取字段 %s 失败: %w", start.Name.Local, err)
-		}
-		setIfEmpty(&info, start.Name.Local, strings.TrimSpace(text))
+func ParseInvoiceInfoFromXbrl(xbrlBytes []byte) (InvoiceInfo, error) {
+	doc, err := ParseXbrlDocument(xbrlBytes)
+	if err != nil {
+		return InvoiceInfo{}, err
 	}
+	return doc.InvoiceInfo()
+}
+
+func finishInvoiceInfo(info InvoiceInfo) (InvoiceInfo, error) {
+	info.PassengerIDMasked = maskIDNumber(info.PassengerIDMasked)
 
 	if strings.TrimSpace(info.DepartureStation) == "" || strings.TrimSpace(info.DestinationStation) == "" {
 		return InvoiceInfo{}, errMissingRequiredField
@@ -55,37 +67,3 @@ func ParseInvoiceInfoFromXbrl(xbrlBytes []byte) (InvoiceInfo, error) {
 	}
 	return info, nil
 }
-
-func isWantedTag(local string) bool {
-	switch local {
-	case "TravelDate", "DateOfIssue", "DepartureStation", "DestinationStation":
-		return true
-	default:
-		return false
-	}
-}
-
-func setIfEmpty(info *InvoiceInfo, local string, value string) {
-	if value == "" {
-		return
-	}
-	switch local {
-	case "TravelDate":
-		if info.TravelDate == "" {
-			info.TravelDate = value
-		}
-	case "DateOfIssue":
-		if info.DateOfIssue == "" {
-			info.DateOfIssue = value
-		}
-	case "DepartureStation":
-		if info.DepartureStation == "" {
-			info.DepartureStation = value
-		}
-	case "DestinationStation":
-		if info.DestinationStation == "" {
-			info.DestinationStation = value
-		}
-	}
-}
-
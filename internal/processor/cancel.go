@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrCanceled = errors.New("处理已取消")
+
+func canceledError(cause error) error {
+	return fmt.Errorf("%w: %w", ErrCanceled, cause)
+}
+
+func (r *runner) fileContext() (context.Context, context.CancelFunc) {
+	if r.cfg.FileTimeout <= 0 {
+		return r.ctx, func() {}
+	}
+	return context.WithTimeout(r.ctx, r.cfg.FileTimeout)
+}
+
+func (r *runner) fileTimeoutError(err error) error {
+	if r.ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("处理超时（超过 %s）: %w", r.cfg.FileTimeout, err)
+	}
+	return err
+}
+
+func (r *runner) failPDF(src SourceRef, info *invoice.InvoiceInfo, err error) {
+	if r.ctx.Err() != nil {
+		return
+	}
+	r.sum.FoundPDF++
+	r.failWithInfo(src, info, err)
+}
@@ -1,10 +1,26 @@
 package processor
 
-import "TrainTicketsTool/internal/invoice"
+import (
+	"TrainTicketsTool/internal/invoice"
+	"time"
+)
 
 type Config struct {
-	InputDir  string
-	OutputDir string
-	DateField invoice.DateField
-}
+	InputDir  string            `json:"inputDir"`
+	OutputDir string            `json:"outputDir"`
+	DateField invoice.DateField `json:"dateField"`
+
+	NameTemplate string    `json:"nameTemplate,omitempty"`
+	DirTemplate  string    `json:"dirTemplate,omitempty"`
+	DedupMode    DedupMode `json:"dedupMode"`
+
+	TransferMode TransferMode `json:"transferMode,omitempty"`
 
+	FileTimeout time.Duration `json:"fileTimeout,omitempty"`
+	Concurrency int           `json:"concurrency,omitempty"`
+
+	Incremental bool `json:"incremental,omitempty"`
+
+	RejectLowConfidence bool     `json:"rejectLowConfidence,omitempty"`
+	PDFPasswords        []string `json:"-"`
+}
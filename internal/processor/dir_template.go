@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	dirTemplateSep    = "/"
+	dirTemplateAltSep = `\`
+)
+
+type dirTemplate struct {
+	segments []nameTemplate
+}
+
+func ValidateDirTemplate(tmpl string) error {
+	_, err := parseDirTemplate(tmpl)
+	return err
+}
+
+func parseDirTemplate(tmpl string) (dirTemplate, error) {
+	s := strings.Trim(strings.TrimSpace(tmpl), dirTemplateSep+dirTemplateAltSep)
+	if s == "" {
+		return dirTemplate{}, nil
+	}
+	parts, err := splitNameTemplate(s)
+	if err != nil {
+		return dirTemplate{}, err
+	}
+
+	var t dirTemplate
+	var cur nameTemplate
+	flush := func() error {
+		if len(cur.parts) == 0 {
+			return fmt.Errorf("目录模板包含空的路径段: %q", s)
+		}
+		t.segments = append(t.segments, cur)
+		cur = nameTemplate{}
+		return nil
+	}
+	for _, p := range parts {
+		if p.field != "" {
+			cur.parts = append(cur.parts, p)
+			continue
+		}
+		pieces := strings.Split(strings.ReplaceAll(p.literal, dirTemplateAltSep, dirTemplateSep), dirTemplateSep)
+		for i, piece := range pieces {
+			if i > 0 {
+				if err := flush(); err != nil {
+					return dirTemplate{}, err
+				}
+			}
+			if piece != "" {
+				cur.parts = append(cur.parts, nameTemplatePart{literal: piece})
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return dirTemplate{}, err
+	}
+	if err := t.validate(); err != nil {
+		return dirTemplate{}, err
+	}
+	return t, nil
+}
+
+func (t dirTemplate) validate() error {
+	for i, seg := range t.segments {
+		for _, p := range seg.parts {
+			if p.field == "" && containsInvalidFileNameChar(p.literal) {
+				return fmt.Errorf("目录模板包含非法字符: %q", p.literal)
+			}
+		}
+		sample, err := seg.renderWith(func(p nameTemplatePart) (string, error) {
+			if nameFields[p.field].kind == nameFieldDate {
+				return formatNameDate(sampleNameDate, p.format), nil
+			}
+			return "x", nil
+		})
+		if err != nil {
+			return err
+		}
+		if sanitizeDirSegment(sample) == "" {
+			return fmt.Errorf("目录模板第 %d 段为空或无效", i+1)
+		}
+	}
+	return nil
+}
+
+func (t dirTemplate) resolve(outputDir string, v nameValues) (string, error) {
+	dir := outputDir
+	for i, seg := range t.segments {
+		raw, err := seg.renderWith(func(p nameTemplatePart) (string, error) {
+			return renderNameField(p, v)
+		})
+		if err != nil {
+			return "", err
+		}
+		name := sanitizeDirSegment(raw)
+		if name == "" {
+			return "", fmt.Errorf("目录模板第 %d 段为空或无效", i+1)
+		}
+		dir = filepath.Join(dir, name)
+	}
+	if !sameDir(dir, outputDir) && !isChildDir(dir, outputDir) {
+		return "", fmt.Errorf("目录模板解析后的路径超出输出目录: %s", dir)
+	}
+	return dir, nil
+}
+
+func sanitizeDirSegment(s string) string {
+	name := SanitizeFileNamePart(s)
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
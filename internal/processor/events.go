@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"errors"
+	"fmt"
+)
+
+type EventKind int
+
+const (
+	EventInfo EventKind = iota
+	EventWritten
+	EventPlanned
+	EventSkipped
+	EventFailed
+	EventRemoved
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventInfo:
+		return "info"
+	case EventWritten:
+		return "ok"
+	case EventPlanned:
+		return "plan"
+	case EventSkipped:
+		return "skip"
+	case EventFailed:
+		return "error"
+	case EventRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+type Event struct {
+	Kind       EventKind
+	Source     SourceRef
+	OutputPath string
+	Info       *invoice.InvoiceInfo
+	Err        error
+	Message    string
+	Summary    Summary
+}
+
+func (e Event) LogLine() string {
+	switch e.Kind {
+	case EventWritten:
+		return fmt.Sprintf("OK: %s -> %s%s", e.Source, e.OutputPath, e.confidenceNote())
+	case EventPlanned:
+		return fmt.Sprintf("PLAN: %s -> %s%s", e.Source, e.OutputPath, e.confidenceNote())
+	case EventSkipped:
+		return fmt.Sprintf("SKIP: %s: %s", e.Message, e.Source)
+	case EventFailed:
+		return fmt.Sprintf("ERR: %s: %v", e.Source, e.Err)
+	case EventRemoved:
+		if e.Message != "" {
+			return fmt.Sprintf("DEL: %s (%s): %s", e.OutputPath, e.Source, e.Message)
+		}
+		return fmt.Sprintf("DEL: %s (%s)", e.OutputPath, e.Source)
+	default:
+		return "INFO: " + e.Message
+	}
+}
+
+func (e Event) confidenceNote() string {
+	if e.Info != nil && e.Info.LowConfidence {
+		return "（文本层识别，请核对）"
+	}
+	return ""
+}
+
+func LogLineHandler(logLine func(string)) func(Event) {
+	return func(e Event) {
+		logLine(e.LogLine())
+	}
+}
+
+func eventHandlerFromLogLine(logLine func(string)) (func(Event), error) {
+	if logLine == nil {
+		return nil, errors.New("logLine 不能为空")
+	}
+	return LogLineHandler(logLine), nil
+}
+
+func (r *runner) emit(e Event) {
+	e.Summary = *r.sum
+	r.onEvent(e)
+}
@@ -0,0 +1,306 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	JournalDirName   = ".invoice-runs"
+	journalExt       = ".jsonl"
+	runIDTimeLayout  = "20060102-150405"
+	runIDRandomBytes = 3
+	LatestRunID      = "latest"
+)
+
+type JournalEntry struct {
+	Source SourceRef `json:"source"`
+	Path   string    `json:"path"`
+	SHA256 string    `json:"sha256"`
+	Moved  bool      `json:"moved,omitempty"`
+}
+
+type Journal struct {
+	RunID     string         `json:"runId"`
+	StartedAt time.Time      `json:"startedAt"`
+	Config    Config         `json:"config"`
+	Entries   []JournalEntry `json:"entries"`
+	UndoneAt  *time.Time     `json:"undoneAt,omitempty"`
+
+	started bool
+	err     error
+}
+
+type journalHeader struct {
+	RunID     string     `json:"runId"`
+	StartedAt time.Time  `json:"startedAt"`
+	Config    Config     `json:"config"`
+	UndoneAt  *time.Time `json:"undoneAt,omitempty"`
+}
+
+func newJournal(cfg Config) (*Journal, error) {
+	now := time.Now()
+	suffix := make([]byte, runIDRandomBytes)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("生成运行 ID 失败: %w", err)
+	}
+	return &Journal{
+		RunID:     now.Format(runIDTimeLayout) + "-" + hex.EncodeToString(suffix),
+		StartedAt: now,
+		Config:    cfg,
+		Entries:   []JournalEntry{},
+	}, nil
+}
+
+func (j *Journal) header() journalHeader {
+	return journalHeader{RunID: j.RunID, StartedAt: j.StartedAt, Config: j.Config, UndoneAt: j.UndoneAt}
+}
+
+func (j *Journal) record(src SourceRef, path string, pdfBytes []byte, moved bool) {
+	if j == nil {
+		return
+	}
+	entry := JournalEntry{Source: src, Path: path, SHA256: sha256Hex(pdfBytes), Moved: moved}
+	j.Entries = append(j.Entries, entry)
+	if j.err == nil {
+		j.err = j.appendEntry(entry)
+	}
+}
+
+func (j *Journal) appendEntry(entry JournalEntry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if !j.started {
+		if err := enc.Encode(j.header()); err != nil {
+			return fmt.Errorf("序列化运行记录失败: %w", err)
+		}
+	}
+	if err := enc.Encode(entry); err != nil {
+		return fmt.Errorf("序列化运行记录失败: %w", err)
+	}
+
+	dir := filepath.Join(j.Config.OutputDir, JournalDirName)
+	if err := EnsureDir(dir); err != nil {
+		return fmt.Errorf("创建运行记录目录失败: %w", err)
+	}
+	f, err := os.OpenFile(journalPath(j.Config.OutputDir, j.RunID), os.O_WRONLY|os.O_CREATE|os.O_APPEND, defaultFileMode)
+	if err != nil {
+		return fmt.Errorf("写入运行记录失败: %w", err)
+	}
+	_, err = f.Write(buf.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入运行记录失败: %w", err)
+	}
+	if !j.started {
+		syncDir(dir)
+		j.started = true
+	}
+	return nil
+}
+
+func journalPath(outputDir string, runID string) string {
+	return filepath.Join(outputDir, JournalDirName, runID+journalExt)
+}
+
+func rewriteJournal(j *Journal) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(j.header()); err != nil {
+		return fmt.Errorf("序列化运行记录失败: %w", err)
+	}
+	for _, entry := range j.Entries {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("序列化运行记录失败: %w", err)
+		}
+	}
+
+	if err := replaceFileAtomic(journalPath(j.Config.OutputDir, j.RunID), buf.Bytes()); err != nil {
+		return fmt.Errorf("写入运行记录失败: %w", err)
+	}
+	return nil
+}
+
+func finishJournal(j *Journal, runErr error) error {
+	if j == nil || j.err == nil {
+		return runErr
+	}
+	if runErr != nil {
+		return fmt.Errorf("%w（%v）", runErr, j.err)
+	}
+	return j.err
+}
+
+func ListJournals(outputDir string) ([]Journal, error) {
+	dir := filepath.Join(outputDir, JournalDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取运行记录目录失败: %w", err)
+	}
+
+	var out []Journal
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), journalExt) {
+			continue
+		}
+		j, err := readJournal(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	sort.SliceStable(out, func(a, b int) bool {
+		return out[a].StartedAt.Before(out[b].StartedAt)
+	})
+	return out, nil
+}
+
+func LoadJournal(outputDir string, runID string) (Journal, error) {
+	if runID == LatestRunID {
+		return latestJournal(outputDir)
+	}
+	if err := ValidateOutputFileName(runID); err != nil {
+		return Journal{}, fmt.Errorf("运行 ID 无效: %w", err)
+	}
+	j, err := readJournal(journalPath(outputDir, runID))
+	if errors.Is(err, os.ErrNotExist) {
+		return Journal{}, fmt.Errorf("未找到运行记录: %s", runID)
+	}
+	return j, err
+}
+
+func latestJournal(outputDir string) (Journal, error) {
+	all, err := ListJournals(outputDir)
+	if err != nil {
+		return Journal{}, err
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].UndoneAt == nil {
+			return all[i], nil
+		}
+	}
+	return Journal{}, errors.New("没有可撤销的运行记录")
+}
+
+func readJournal(path string) (Journal, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Journal{}, err
+	}
+	lines := bytes.Split(bytes.TrimRight(b, "\n"), []byte("\n"))
+	var h journalHeader
+	if err := json.Unmarshal(lines[0], &h); err != nil {
+		return Journal{}, fmt.Errorf("解析运行记录失败（%s）: %w", filepath.Base(path), err)
+	}
+	j := Journal{RunID: h.RunID, StartedAt: h.StartedAt, Config: h.Config, UndoneAt: h.UndoneAt, Entries: []JournalEntry{}}
+	for i, line := range lines[1:] {
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if i == len(lines)-2 && !bytes.HasSuffix(b, []byte("\n")) {
+				break
+			}
+			return Journal{}, fmt.Errorf("解析运行记录失败（%s 第 %d 行）: %w", filepath.Base(path), i+2, err)
+		}
+		j.Entries = append(j.Entries, entry)
+	}
+	return j, nil
+}
+
+func UndoRun(outputDir string, runID string, logLine func(string)) (Summary, error) {
+	onEvent, err := eventHandlerFromLogLine(logLine)
+	if err != nil {
+		return Summary{}, err
+	}
+	return UndoRunWithEvents(context.Background(), outputDir, runID, onEvent)
+}
+
+func UndoRunWithEvents(ctx context.Context, outputDir string, runID string, onEvent func(Event)) (Summary, error) {
+	if onEvent == nil {
+		return Summary{}, errors.New("onEvent 不能为空")
+	}
+	outAbs, err := absClean(outputDir)
+	if err != nil {
+		return Summary{}, fmt.Errorf("输出目录无效: %w", err)
+	}
+	j, err := LoadJournal(outAbs, runID)
+	if err != nil {
+		return Summary{}, err
+	}
+	if j.UndoneAt != nil {
+		return Summary{}, fmt.Errorf("运行 %s 已于 %s 撤销", j.RunID, j.UndoneAt.Format(time.DateTime))
+	}
+
+	sum := Summary{FoundPDF: len(j.Entries)}
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return sum, canceledError(err)
+		}
+		entry := j.Entries[i]
+		removed, err := undoEntry(outAbs, entry)
+		switch {
+		case err != nil:
+			sum.Failed++
+			onEvent(Event{Kind: EventFailed, Source: entry.Source, OutputPath: entry.Path, Err: err, Summary: sum})
+		case !removed:
+			onEvent(Event{Kind: EventSkipped, Source: entry.Source, OutputPath: entry.Path, Message: "输出文件已不存在", Summary: sum})
+		default:
+			sum.Succeeded++
+			var msg string
+			if entry.Moved {
+				msg = "已移回原位置"
+			}
+			onEvent(Event{Kind: EventRemoved, Source: entry.Source, OutputPath: entry.Path, Message: msg, Summary: sum})
+		}
+	}
+
+	if sum.Failed > 0 {
+		return sum, nil
+	}
+	now := time.Now()
+	j.UndoneAt = &now
+	j.Config.OutputDir = outAbs
+	return sum, rewriteJournal(&j)
+}
+
+func undoEntry(outputDir string, entry JournalEntry) (bool, error) {
+	if !entry.Moved && !sameDir(filepath.Dir(entry.Path), outputDir) && !isChildDir(entry.Path, outputDir) {
+		return false, fmt.Errorf("路径不在输出目录内，拒绝删除: %s", entry.Path)
+	}
+	b, err := os.ReadFile(entry.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("读取输出文件失败: %w", err)
+	}
+	if sha256Hex(b) != entry.SHA256 {
+		return false, errors.New("文件自写入后已被修改，拒绝删除")
+	}
+	if entry.Moved {
+		return true, restoreMovedFile(entry, b)
+	}
+	if err := os.Remove(entry.Path); err != nil {
+		return false, fmt.Errorf("删除输出文件失败: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,306 @@
+package processor
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	emlExt  = ".eml"
+	mboxExt = ".mbox"
+
+	mimeTypePDF     = "application/pdf"
+	mimeTypeOFD     = "application/ofd"
+	mimeTypeZip     = "application/zip"
+	mimeTypeZipAlt  = "application/x-zip-compressed"
+	mimeTypeMessage = "message/rfc822"
+
+	mboxSeparator      = "From "
+	mboxMessageNameFmt = "message-%d" + emlExt
+)
+
+type mailAttachment struct {
+	name string
+	data []byte
+}
+
+type mimeHeader interface {
+	Get(key string) string
+}
+
+var mailWordDecoder = &mime.WordDecoder{}
+
+func parseMailAttachments(b []byte) ([]mailAttachment, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("解析邮件失败: %w", err)
+	}
+	c := &mailAttachmentCollector{}
+	if err := c.walkPart(msg.Header, msg.Body); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(c.attachments))
+	for i, a := range c.attachments {
+		names[i] = a.name
+	}
+	for i, name := range uniqueEntryNames(names) {
+		c.attachments[i].name = name
+	}
+	return c.attachments, nil
+}
+
+type mailAttachmentCollector struct {
+	attachments []mailAttachment
+	unnamed     int
+}
+
+func (c *mailAttachmentCollector) walkPart(h mimeHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		boundary := params["boundary"]
+		if boundary == "" {
+			return errors.New("multipart 缺少 boundary")
+		}
+		mr := multipart.NewReader(body, boundary)
+		for {
+			part, err := mr.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("读取 MIME 分段失败: %w", err)
+			}
+			if err := c.walkPart(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	name := c.attachmentName(h, mediaType, params)
+	if name == "" {
+		return nil
+	}
+	data, err := io.ReadAll(decodeTransferEncoding(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("解码附件 %s 失败: %w", name, err)
+	}
+	c.attachments = append(c.attachments, mailAttachment{name: name, data: data})
+	return nil
+}
+
+func (c *mailAttachmentCollector) attachmentName(h mimeHeader, mediaType string, params map[string]string) string {
+	name := ""
+	if _, dispParams, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil {
+		name = dispParams["filename"]
+	}
+	if name == "" {
+		name = params["name"]
+	}
+	name = decodeMailWords(name)
+	if name != "" {
+		name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	}
+	if isMailContainerName(name) {
+		return name
+	}
+
+	ext := ""
+	switch mediaType {
+	case mimeTypePDF:
+		ext = pdfExt
+	case mimeTypeOFD:
+		ext = ofdExt
+	case mimeTypeZip, mimeTypeZipAlt:
+		ext = zipExt
+	case mimeTypeMessage:
+		ext = emlExt
+	default:
+		return ""
+	}
+	if name != "" {
+		return name + ext
+	}
+	c.unnamed++
+	return fmt.Sprintf("attachment-%d%s", c.unnamed, ext)
+}
+
+func isMailContainerName(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case pdfExt, ofdExt, zipExt, emlExt:
+		return true
+	default:
+		return false
+	}
+}
+
+func decodeMailWords(s string) string {
+	decoded, err := mailWordDecoder.DecodeHeader(s)
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(decoded)
+}
+
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+func splitMbox(b []byte) [][]byte {
+	var messages [][]byte
+	var cur bytes.Buffer
+	inMessage := false
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 0, 64*1024), len(b)+1)
+	sc.Split(scanLinesKeepEOL)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if bytes.HasPrefix(line, []byte(mboxSeparator)) {
+			if inMessage {
+				messages = append(messages, bytes.Clone(cur.Bytes()))
+				cur.Reset()
+			}
+			inMessage = true
+			continue
+		}
+		if !inMessage {
+			continue
+		}
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte(mboxSeparator)) {
+			line = line[1:]
+		}
+		cur.Write(line)
+	}
+	if inMessage {
+		messages = append(messages, bytes.Clone(cur.Bytes()))
+	}
+	return messages
+}
+
+func scanLinesKeepEOL(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func mboxMessageName(index int) string {
+	return fmt.Sprintf(mboxMessageNameFmt, index+1)
+}
+
+func readMailAttachmentByName(b []byte, name string) ([]byte, error) {
+	attachments, err := parseMailAttachments(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		if a.name == name {
+			return a.data, nil
+		}
+	}
+	return nil, fmt.Errorf("邮件内未找到附件: %s", name)
+}
+
+func readMboxMessageByName(b []byte, name string) ([]byte, error) {
+	for i, msg := range splitMbox(b) {
+		if mboxMessageName(i) == name {
+			return msg, nil
+		}
+	}
+	return nil, fmt.Errorf("mbox 内未找到: %s", name)
+}
+
+func (r *runner) processMailFile(src SourceRef) error {
+	b, err := os.ReadFile(src.FilePath)
+	if err != nil {
+		return fmt.Errorf("读取邮件失败: %w", err)
+	}
+	return r.processMailBytes(src, b)
+}
+
+func (r *runner) processMailBytes(src SourceRef, b []byte) error {
+	attachments, err := parseMailAttachments(b)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		child := src.child(a.name)
+		if err := r.processEntryBytes(child, a.name, a.data); err != nil {
+			if ctxErr := r.ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			r.submitFailure(child, err)
+		}
+	}
+	return nil
+}
+
+func (r *runner) processMboxFile(src SourceRef) error {
+	b, err := os.ReadFile(src.FilePath)
+	if err != nil {
+		return fmt.Errorf("读取 mbox 失败: %w", err)
+	}
+	for i, msg := range splitMbox(b) {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		child := src.child(mboxMessageName(i))
+		if err := r.processMailBytes(child, msg); err != nil {
+			if ctxErr := r.ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			r.submitFailure(child, err)
+		}
+	}
+	return nil
+}
+
+func (r *runner) processEntryBytes(src SourceRef, name string, b []byte) error {
+	switch strings.ToLower(path.Ext(name)) {
+	case pdfExt, ofdExt:
+		r.submitPDF(src, "", func() ([]byte, error) { return b, nil }, true)
+		return nil
+	case zipExt:
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return fmt.Errorf("解析 ZIP 失败: %w", err)
+		}
+		return r.processZipReader(src, zr)
+	case emlExt:
+		return r.processMailBytes(src, b)
+	default:
+		return nil
+	}
+}
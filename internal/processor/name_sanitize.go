@@ -28,3 +28,11 @@ func SanitizeFileNamePart(input string) string {
 	return s
 }
 
+func containsInvalidFileNameChar(s string) bool {
+	for _, ch := range windowsInvalidFileNameChars {
+		if strings.Contains(s, ch) {
+			return true
+		}
+	}
+	return strings.ContainsAny(s, "\r\n\t")
+}
@@ -0,0 +1,303 @@
+package processor
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultNameTemplate = "{date}-{from}-{to}.pdf"
+
+	nameTemplateOpen      = '{'
+	nameTemplateClose     = '}'
+	nameTemplateFormatSep = ":"
+)
+
+type nameFieldKind int
+
+const (
+	nameFieldText nameFieldKind = iota
+	nameFieldDate
+)
+
+type nameValues struct {
+	info      invoice.InvoiceInfo
+	dateField invoice.DateField
+}
+
+type nameField struct {
+	kind  nameFieldKind
+	value func(v nameValues) (string, error)
+}
+
+var nameFields = map[string]nameField{
+	"date": {kind: nameFieldDate, value: func(v nameValues) (string, error) {
+		return pickDate(v.info, v.dateField)
+	}},
+	"travelDate": {kind: nameFieldDate, value: func(v nameValues) (string, error) {
+		return v.info.TravelDate, nil
+	}},
+	"issueDate": {kind: nameFieldDate, value: func(v nameValues) (string, error) {
+		return v.info.DateOfIssue, nil
+	}},
+	"from": {kind: nameFieldText, value: func(v nameValues) (string, error) {
+		return v.info.DepartureStation, nil
+	}},
+	"to": {kind: nameFieldText, value: func(v nameValues) (string, error) {
+		return v.info.DestinationStation, nil
+	}},
+	"invoiceNumber": infoTextField(func(i invoice.InvoiceInfo) string { return i.InvoiceNumber }),
+	"ticketNumber":  infoTextField(func(i invoice.InvoiceInfo) string { return i.ElectronicTicketNumber }),
+	"train":         infoTextField(func(i invoice.InvoiceInfo) string { return i.TrainNumber }),
+	"seatClass":     infoTextField(func(i invoice.InvoiceInfo) string { return i.SeatClass }),
+	"carriage":      infoTextField(func(i invoice.InvoiceInfo) string { return i.Carriage }),
+	"seat":          infoTextField(func(i invoice.InvoiceInfo) string { return i.SeatNumber }),
+	"departureTime": infoTextField(func(i invoice.InvoiceInfo) string { return i.DepartureTime }),
+	"passenger":     infoTextField(func(i invoice.InvoiceInfo) string { return i.PassengerName }),
+	"passengerId":   infoTextField(func(i invoice.InvoiceInfo) string { return i.PassengerIDMasked }),
+	"amount":        infoTextField(func(i invoice.InvoiceInfo) string { return i.Fare }),
+	"taxRate":       infoTextField(func(i invoice.InvoiceInfo) string { return i.TaxRate }),
+	"taxAmount":     infoTextField(func(i invoice.InvoiceInfo) string { return i.TaxAmount }),
+	"buyer":         infoTextField(func(i invoice.InvoiceInfo) string { return i.BuyerName }),
+	"buyerTaxId":    infoTextField(func(i invoice.InvoiceInfo) string { return i.BuyerTaxID }),
+}
+
+func infoTextField(get func(info invoice.InvoiceInfo) string) nameField {
+	return nameField{kind: nameFieldText, value: func(v nameValues) (string, error) {
+		return get(v.info), nil
+	}}
+}
+
+type nameTemplatePart struct {
+	literal string
+	field   string
+	format  string
+}
+
+type nameTemplate struct {
+	parts []nameTemplatePart
+}
+
+func ValidateNameTemplate(tmpl string) error {
+	_, err := parseNameTemplate(tmpl)
+	return err
+}
+
+func parseNameTemplate(tmpl string) (nameTemplate, error) {
+	s := strings.TrimSpace(tmpl)
+	if s == "" {
+		s = DefaultNameTemplate
+	}
+	if !strings.HasSuffix(strings.ToLower(s), pdfExt) {
+		s += pdfExt
+	}
+
+	parts, err := splitNameTemplate(s)
+	if err != nil {
+		return nameTemplate{}, err
+	}
+	t := nameTemplate{parts: parts}
+	if err := t.validate(); err != nil {
+		return nameTemplate{}, err
+	}
+	return t, nil
+}
+
+func splitNameTemplate(s string) ([]nameTemplatePart, error) {
+	var parts []nameTemplatePart
+	var lit strings.Builder
+	flushLiteral := func() {
+		if lit.Len() > 0 {
+			parts = append(parts, nameTemplatePart{literal: lit.String()})
+			lit.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case nameTemplateOpen:
+			if i+1 < len(s) && s[i+1] == nameTemplateOpen {
+				lit.WriteByte(c)
+				i++
+				continue
+			}
+			end := strings.IndexByte(s[i+1:], nameTemplateClose)
+			if end < 0 {
+				return nil, fmt.Errorf("命名模板缺少 '}': %q", s)
+			}
+			part, err := parsePlaceholder(s[i+1 : i+1+end])
+			if err != nil {
+				return nil, err
+			}
+			flushLiteral()
+			parts = append(parts, part)
+			i += end + 1
+		case nameTemplateClose:
+			if i+1 < len(s) && s[i+1] == nameTemplateClose {
+				lit.WriteByte(c)
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("命名模板中存在多余的 '}': %q", s)
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	flushLiteral()
+	return parts, nil
+}
+
+func parsePlaceholder(body string) (nameTemplatePart, error) {
+	name, format, hasFormat := strings.Cut(body, nameTemplateFormatSep)
+	name = strings.TrimSpace(name)
+	field, ok := nameFields[name]
+	if !ok {
+		return nameTemplatePart{}, fmt.Errorf("命名模板包含未知字段: {%s}", body)
+	}
+	if hasFormat {
+		if field.kind != nameFieldDate {
+			return nameTemplatePart{}, fmt.Errorf("命名模板字段 {%s} 不支持格式", name)
+		}
+		if strings.TrimSpace(format) == "" {
+			return nameTemplatePart{}, fmt.Errorf("命名模板字段 {%s} 的日期格式为空", name)
+		}
+	}
+	return nameTemplatePart{field: name, format: format}, nil
+}
+
+func (t nameTemplate) validate() error {
+	for _, p := range t.parts {
+		if p.field != "" {
+			continue
+		}
+		if containsInvalidFileNameChar(p.literal) {
+			return fmt.Errorf("命名模板包含非法字符: %q", p.literal)
+		}
+	}
+
+	sample, err := t.renderWith(func(p nameTemplatePart) (string, error) {
+		if nameFields[p.field].kind == nameFieldDate {
+			return formatNameDate(sampleNameDate, p.format), nil
+		}
+		return "x", nil
+	})
+	if err != nil {
+		return err
+	}
+	return validateRenderedFileName(sample)
+}
+
+var sampleNameDate = time.Date(2006, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+func (t nameTemplate) render(v nameValues) (string, error) {
+	name, err := t.renderWith(func(p nameTemplatePart) (string, error) {
+		return renderNameField(p, v)
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := validateRenderedFileName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func (t nameTemplate) renderWith(value func(p nameTemplatePart) (string, error)) (string, error) {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.field == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		s, err := value(p)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s)
+	}
+	return b.String(), nil
+}
+
+func renderNameField(p nameTemplatePart, v nameValues) (string, error) {
+	field := nameFields[p.field]
+	raw, err := field.value(v)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(raw) == "" {
+		return "", fmt.Errorf("命名字段 {%s} 为空", p.field)
+	}
+
+	switch field.kind {
+	case nameFieldDate:
+		date, err := NormalizeDate(raw)
+		if err != nil {
+			return "", err
+		}
+		if p.format == "" {
+			return date, nil
+		}
+		t, err := time.Parse(dateLayoutDash, date)
+		if err != nil {
+			return "", err
+		}
+		return formatNameDate(t, p.format), nil
+	default:
+		s := SanitizeFileNamePart(raw)
+		if s == "" {
+			return "", fmt.Errorf("命名字段 {%s} 为空", p.field)
+		}
+		return s, nil
+	}
+}
+
+var nameDateTokens = []struct {
+	token  string
+	layout string
+}{
+	{"yyyy", "2006"},
+	{"yy", "06"},
+	{"MM", "01"},
+	{"M", "1"},
+	{"dd", "02"},
+	{"d", "2"},
+}
+
+func formatNameDate(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		matched := false
+		for _, tok := range nameDateTokens {
+			if strings.HasPrefix(format[i:], tok.token) {
+				b.WriteString(t.Format(tok.layout))
+				i += len(tok.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+func validateRenderedFileName(name string) error {
+	if err := ValidateOutputFileName(name); err != nil {
+		return err
+	}
+	if containsInvalidFileNameChar(name) {
+		return fmt.Errorf("输出文件名包含非法字符: %q", name)
+	}
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	if SanitizeFileNamePart(base) == "" {
+		return errors.New("输出文件名为空")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	tempFilePrefix  = ".invoice-"
+	tempFileSuffix  = ".tmp"
+	tempFilePattern = tempFilePrefix + "*" + tempFileSuffix
+	staleTempAge    = time.Hour
+)
+
+func IsTempFile(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, tempFilePrefix) && strings.HasSuffix(name, tempFileSuffix)
+}
+
+func removeStaleTempFiles(dir string) int {
+	cutoff := time.Now().Add(-staleTempAge)
+	removed := 0
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !IsTempFile(path) {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().Before(cutoff) && os.Remove(path) == nil {
+			removed++
+		}
+		return nil
+	})
+	return removed
+}
+
+func logStaleTempFiles(dir string, onEvent func(Event)) {
+	if n := removeStaleTempFiles(dir); n > 0 {
+		onEvent(Event{Kind: EventInfo, Message: fmt.Sprintf("已清理 %d 个上次中断遗留的临时文件", n)})
+	}
+}
+
+func nextFreePath(dir string, fileName string) func() (string, error) {
+	return func() (string, error) {
+		return UniqueOutputPath(dir, fileName)
+	}
+}
+
+func fixedPath(path string) func() (string, error) {
+	return func() (string, error) {
+		exists, err := fileExists(path)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return "", fmt.Errorf("%w: %s", errTargetExists, path)
+		}
+		return path, nil
+	}
+}
+
+func commitNoReplace(pick func() (string, error), commit func(target string) error) (string, error) {
+	for {
+		target, err := pick()
+		if err != nil {
+			return "", err
+		}
+		err = commit(target)
+		if err == nil {
+			return target, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+	}
+}
+
+func writeFileAtomic(dir string, pick func() (string, error), data []byte) (string, error) {
+	tmp, err := writeTempFile(dir, data)
+	if err != nil {
+		return "", err
+	}
+	target, err := commitNoReplace(pick, func(target string) error {
+		return renameNoReplace(tmp, target)
+	})
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("写入输出文件失败: %w", err)
+	}
+	syncDir(dir)
+	return target, nil
+}
+
+func replaceFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := writeTempFile(dir, data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+func writeTempFile(dir string, data []byte) (string, error) {
+	f, err := os.CreateTemp(dir, tempFilePattern)
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmp := f.Name()
+	if err := writeAndSync(f, data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	return tmp, nil
+}
+
+func writeAndSync(f *os.File, data []byte) error {
+	if err := f.Chmod(defaultFileMode); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func moveNoReplace(from string, dir string, pick func() (string, error), data []byte) (string, error) {
+	target, err := commitNoReplace(pick, func(target string) error {
+		return renameNoReplace(from, target)
+	})
+	if !isLinkError(err) {
+		if err == nil {
+			syncDir(dir)
+		}
+		return target, err
+	}
+	if target, err = writeFileAtomic(dir, pick, data); err != nil {
+		return "", err
+	}
+	if err := os.Remove(from); err != nil {
+		_ = os.Remove(target)
+		return "", fmt.Errorf("删除源文件失败: %w", err)
+	}
+	return target, nil
+}
+
+func isLinkError(err error) bool {
+	var linkErr *os.LinkError
+	return errors.As(err, &linkErr)
+}
@@ -0,0 +1,26 @@
+package processor
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func linkNoReplace(from string, to string) error {
+	if err := os.Link(from, to); err != nil {
+		return err
+	}
+	if err := os.Remove(from); err != nil {
+		_ = os.Remove(to)
+		return err
+	}
+	return nil
+}
+
+func renameOrLink(rename func(from string, to string) error, from string, to string) error {
+	err := rename(from, to)
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.ENOSYS) {
+		return linkNoReplace(from, to)
+	}
+	return err
+}
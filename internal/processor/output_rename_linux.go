@@ -0,0 +1,20 @@
+//go:build linux
+
+package processor
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func renameNoReplace(from string, to string) error {
+	return renameOrLink(renameat2NoReplace, from, to)
+}
+
+func renameat2NoReplace(from string, to string) error {
+	if err := unix.Renameat2(unix.AT_FDCWD, from, unix.AT_FDCWD, to, unix.RENAME_NOREPLACE); err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+	return nil
+}
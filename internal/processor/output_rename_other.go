@@ -0,0 +1,7 @@
+//go:build !windows && !linux
+
+package processor
+
+func renameNoReplace(from string, to string) error {
+	return linkNoReplace(from, to)
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package processor
+
+import (
+	"os"
+	"syscall"
+)
+
+func renameNoReplace(from string, to string) error {
+	src, err := syscall.UTF16PtrFromString(from)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+	dst, err := syscall.UTF16PtrFromString(to)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+	if err := syscall.MoveFile(src, dst); err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+	return nil
+}
+
+func syncDir(string) {}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package processor
+
+import "os"
+
+func syncDir(dir string) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = f.Sync()
+	_ = f.Close()
+}
@@ -23,19 +23,16 @@ func WritePDF(outputDir string, fileName string, pdfBytes []byte) (string, error
 		return "", fmt.Errorf("创建输出目录失败: %w", err)
 	}
 
-	outPath, err := UniqueOutputPath(outputDir, fileName)
-	if err != nil {
-		return "", err
-	}
-	if err := os.WriteFile(outPath, pdfBytes, defaultFileMode); err != nil {
-		return "", fmt.Errorf("写入输出文件失败: %w", err)
-	}
-	return outPath, nil
+	return writeFileAtomic(outputDir, nextFreePath(outputDir, fileName), pdfBytes)
 }
 
 func UniqueOutputPath(outputDir string, fileName string) (string, error) {
+	return uniqueOutputPathWith(outputDir, fileName, fileExists)
+}
+
+func uniqueOutputPathWith(outputDir string, fileName string, taken func(string) (bool, error)) (string, error) {
 	basePath := filepath.Join(outputDir, fileName)
-	exists, err := fileExists(basePath)
+	exists, err := taken(basePath)
 	if err != nil {
 		return "", err
 	}
@@ -48,7 +45,7 @@ func UniqueOutputPath(outputDir string, fileName string) (string, error) {
 	for i := firstCollisionNum; ; i++ {
 		tryName := fmt.Sprintf("%s-%d%s", base, i, ext)
 		tryPath := filepath.Join(outputDir, tryName)
-		exists, err := fileExists(tryPath)
+		exists, err := taken(tryPath)
 		if err != nil {
 			return "", err
 		}
@@ -3,9 +3,12 @@ package processor
 import (
 	"fmt"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
+var caseInsensitivePaths = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
 func normalizeConfigDirs(cfg Config) (Config, error) {
 	inAbs, err := absClean(cfg.InputDir)
 	if err != nil {
@@ -15,11 +18,10 @@ func normalizeConfigDirs(cfg Config) (Config, error) {
 	if err != nil {
 		return Config{}, fmt.Errorf("输出目录无效: %w", err)
 	}
-	return Config{
-		InputDir:  inAbs,
-		OutputDir: outAbs,
-		DateField: cfg.DateField,
-	}, nil
+	out := cfg
+	out.InputDir = inAbs
+	out.OutputDir = outAbs
+	return out, nil
 }
 
 func absClean(path string) (string, error) {
@@ -48,3 +50,9 @@ func isChildDir(child string, parent string) bool {
 	return strings.HasPrefix(c, p+sep)
 }
 
+func pathKey(path string) string {
+	if caseInsensitivePaths {
+		return strings.ToLower(path)
+	}
+	return path
+}
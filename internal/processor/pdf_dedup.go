@@ -1,11 +1,56 @@
 package processor
 
 import (
+	"TrainTicketsTool/internal/invoice"
+	"fmt"
 	"path"
 	"path/filepath"
 	"strings"
 )
 
+type DedupMode int
+
+const (
+	DedupByFileName DedupMode = iota
+	DedupBySHA256
+	DedupByInvoiceNumber
+)
+
+func (m DedupMode) String() string {
+	switch m {
+	case DedupByFileName:
+		return "name"
+	case DedupBySHA256:
+		return "sha256"
+	case DedupByInvoiceNumber:
+		return "invoice"
+	default:
+		return "unknown"
+	}
+}
+
+func (m DedupMode) label() string {
+	switch m {
+	case DedupByFileName:
+		return "文件名"
+	case DedupBySHA256:
+		return "SHA-256"
+	case DedupByInvoiceNumber:
+		return "发票号码"
+	default:
+		return "未知"
+	}
+}
+
+type dedupKey struct {
+	mode  DedupMode
+	value string
+}
+
+func (k dedupKey) mapKey() string {
+	return k.mode.String() + ":" + k.value
+}
+
 func pdfDedupKeyFromFilePath(filePath string) string {
 	return normalizePDFDedupKey(filepath.Base(filePath))
 }
@@ -18,17 +63,55 @@ func normalizePDFDedupKey(name string) string {
 	return strings.ToLower(strings.TrimSpace(name))
 }
 
-func (r *runner) shouldProcessPDFByKey(key string) bool {
-	if key == "" {
-		return true
+func (r *runner) fileNameDedupKey(src SourceRef) dedupKey {
+	if r.cfg.DedupMode != DedupByFileName {
+		return dedupKey{}
+	}
+	if n := len(src.Entries); n > 0 {
+		return dedupKey{mode: DedupByFileName, value: pdfDedupKeyFromZipEntryName(src.Entries[n-1])}
+	}
+	return dedupKey{mode: DedupByFileName, value: pdfDedupKeyFromFilePath(src.FilePath)}
+}
+
+func (r *runner) contentDedupKey(pdfBytes []byte) dedupKey {
+	if r.cfg.DedupMode != DedupBySHA256 {
+		return dedupKey{}
+	}
+	return dedupKey{mode: DedupBySHA256, value: sha256Hex(pdfBytes)}
+}
+
+func (r *runner) invoiceDedupKey(info invoice.InvoiceInfo, pdfBytes []byte) dedupKey {
+	if r.cfg.DedupMode != DedupByInvoiceNumber {
+		return dedupKey{}
+	}
+	if num := strings.TrimSpace(info.InvoiceNumber); num != "" {
+		return dedupKey{mode: DedupByInvoiceNumber, value: num}
+	}
+	return dedupKey{mode: DedupBySHA256, value: sha256Hex(pdfBytes)}
+}
+
+func (r *runner) skipIfDuplicate(src SourceRef, key dedupKey) bool {
+	first, dup := findDuplicate(r.seenContent, src, key)
+	if dup {
+		r.logSkipDuplicatePDF(src, key, first)
+	}
+	return dup
+}
+
+func findDuplicate(seen map[string]SourceRef, src SourceRef, key dedupKey) (SourceRef, bool) {
+	if key.value == "" {
+		return SourceRef{}, false
 	}
-	if _, exists := r.seenPDFNames[key]; exists {
-		return false
+	mk := key.mapKey()
+	if first, exists := seen[mk]; exists && first.String() != src.String() {
+		return first, true
 	}
-	r.seenPDFNames[key] = struct{}{}
-	return true
+	seen[mk] = src
+	return SourceRef{}, false
 }
 
-func (r *runner) logSkipDuplicatePDF(source string) {
-	r.logLine("SKIP: 重名 PDF（按文件名去重）: " + source)
+func (r *runner) logSkipDuplicatePDF(src SourceRef, key dedupKey, first SourceRef) {
+	reason := fmt.Sprintf("重复 PDF（按%s去重，%s=%s，与 %s 重复）", key.mode.label(), key.mode.label(), key.value, first)
+	r.recordPlan(PlannedOp{Source: src, Action: PlanSkip, Reason: reason})
+	r.emit(Event{Kind: EventSkipped, Source: src, Message: reason})
 }
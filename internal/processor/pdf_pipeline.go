@@ -0,0 +1,160 @@
+package processor
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"fmt"
+)
+
+const pipelineQueueFactor = 2
+
+var extractInvoiceInfo = invoice.ExtractInvoiceInfo
+
+type pdfJob struct {
+	src     SourceRef
+	load    func() ([]byte, error)
+	walkErr error
+
+	dup      bool
+	dupKey   dedupKey
+	dupFirst SourceRef
+
+	stateKey  string
+	processed *StateEntry
+
+	pdfBytes   []byte
+	info       invoice.InvoiceInfo
+	loadErr    error
+	extractErr error
+	done       chan struct{}
+}
+
+type pdfPipeline struct {
+	order chan *pdfJob
+	work  chan *pdfJob
+}
+
+func (r *runner) walk() error {
+	return r.walkWith(r.walkInput)
+}
+
+func (r *runner) walkWith(produce func() error) error {
+	workers := r.cfg.Concurrency
+	if workers <= 1 {
+		return produce()
+	}
+
+	p := &pdfPipeline{
+		order: make(chan *pdfJob, workers*pipelineQueueFactor),
+		work:  make(chan *pdfJob, workers),
+	}
+	r.pipe = p
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range p.work {
+				r.extract(job)
+				close(job.done)
+			}
+		}()
+	}
+
+	walkErr := make(chan error, 1)
+	go func() {
+		err := produce()
+		close(p.work)
+		close(p.order)
+		walkErr <- err
+	}()
+
+	for job := range p.order {
+		<-job.done
+		r.consume(job)
+	}
+	return <-walkErr
+}
+
+func (r *runner) submitPDF(src SourceRef, stateKey string, load func() ([]byte, error), readNow bool) {
+	job := &pdfJob{src: src, load: load, stateKey: stateKey}
+	key := r.fileNameDedupKey(src)
+	if first, dup := findDuplicate(r.seenNames, src, key); dup {
+		job.load = nil
+		job.dup = true
+		job.dupKey = key
+		job.dupFirst = first
+		r.submit(job)
+		return
+	}
+	if readNow {
+		b, err := load()
+		job.load = func() ([]byte, error) { return b, err }
+		if err == nil && r.state != nil && job.stateKey == "" {
+			job.stateKey = entryStateKey(src, b)
+		}
+	}
+	if entry, ok := r.state.lookup(job.stateKey); ok {
+		job.load = nil
+		job.processed = &entry
+	}
+	r.submit(job)
+}
+
+func (r *runner) submitFailure(src SourceRef, err error) {
+	r.submit(&pdfJob{src: src, walkErr: err})
+}
+
+func (r *runner) submit(job *pdfJob) {
+	if r.pipe == nil {
+		if job.load != nil {
+			r.extract(job)
+		}
+		r.consume(job)
+		return
+	}
+
+	job.done = make(chan struct{})
+	r.pipe.order <- job
+	if job.load == nil {
+		close(job.done)
+		return
+	}
+	r.pipe.work <- job
+}
+
+func (r *runner) extract(job *pdfJob) {
+	defer func() {
+		if p := recover(); p != nil {
+			err := fmt.Errorf("处理时发生内部错误: %v", p)
+			if job.pdfBytes == nil {
+				job.loadErr = err
+			} else {
+				job.extractErr = err
+			}
+		}
+	}()
+
+	pdfBytes, err := job.load()
+	if err != nil {
+		job.loadErr = err
+		return
+	}
+	job.pdfBytes = pdfBytes
+
+	fileCtx, cancel := r.fileContext()
+	defer cancel()
+	job.info, job.extractErr = extractInvoiceInfo(fileCtx, job.src.baseName(), pdfBytes, r.cfg.PDFPasswords)
+}
+
+func (r *runner) consume(job *pdfJob) {
+	if r.ctx.Err() != nil {
+		return
+	}
+	switch {
+	case job.walkErr != nil:
+		r.fail(job.src, job.walkErr)
+	case job.dup:
+		r.logSkipDuplicatePDF(job.src, job.dupKey, job.dupFirst)
+	case job.processed != nil:
+		r.logSkipProcessed(job.src, *job.processed)
+	default:
+		r.finishPDF(job)
+	}
+}
@@ -0,0 +1,195 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type PlanAction string
+
+const (
+	PlanWrite PlanAction = "write"
+	PlanSkip  PlanAction = "skip"
+	PlanFail  PlanAction = "fail"
+)
+
+type PlannedOp struct {
+	Source SourceRef  `json:"source"`
+	Target string     `json:"target,omitempty"`
+	Action PlanAction `json:"action"`
+	Reason string     `json:"reason,omitempty"`
+	SHA256 string     `json:"sha256,omitempty"`
+}
+
+type Plan struct {
+	Config  Config      `json:"config"`
+	Summary Summary     `json:"summary"`
+	Ops     []PlannedOp `json:"ops"`
+}
+
+func BuildPlan(cfg Config, logLine func(string)) (Plan, error) {
+	onEvent, err := eventHandlerFromLogLine(logLine)
+	if err != nil {
+		return Plan{}, err
+	}
+	return BuildPlanWithEvents(context.Background(), cfg, onEvent)
+}
+
+func BuildPlanWithEvents(ctx context.Context, cfg Config, onEvent func(Event)) (Plan, error) {
+	r, err := newRunner(ctx, cfg, onEvent)
+	if err != nil {
+		return Plan{}, err
+	}
+	r.plan = &Plan{Config: r.cfg, Ops: []PlannedOp{}}
+	r.reserved = make(map[string]struct{})
+
+	walkErr := r.walk()
+	r.plan.Summary = *r.sum
+	return *r.plan, walkErr
+}
+
+func (r *runner) recordPlan(op PlannedOp) {
+	if r.plan == nil {
+		return
+	}
+	r.plan.Ops = append(r.plan.Ops, op)
+}
+
+func (r *runner) planWrite(src SourceRef, outDir string, fileName string, pdfBytes []byte) (string, error) {
+	outPath, err := uniqueOutputPathWith(outDir, fileName, r.isPlannedOrExisting)
+	if err != nil {
+		return "", err
+	}
+	r.reserved[plannedPathKey(outPath)] = struct{}{}
+	r.recordPlan(PlannedOp{
+		Source: src,
+		Target: outPath,
+		Action: PlanWrite,
+		SHA256: sha256Hex(pdfBytes),
+	})
+	return outPath, nil
+}
+
+func (r *runner) isPlannedOrExisting(path string) (bool, error) {
+	if _, ok := r.reserved[plannedPathKey(path)]; ok {
+		return true, nil
+	}
+	return fileExists(path)
+}
+
+func plannedPathKey(path string) string {
+	return pathKey(path)
+}
+
+func ApplyPlan(plan Plan, logLine func(string)) (Summary, error) {
+	onEvent, err := eventHandlerFromLogLine(logLine)
+	if err != nil {
+		return Summary{}, err
+	}
+	return ApplyPlanWithEvents(context.Background(), plan, onEvent)
+}
+
+func ApplyPlanWithEvents(ctx context.Context, plan Plan, onEvent func(Event)) (Summary, error) {
+	if onEvent == nil {
+		return Summary{}, errors.New("onEvent 不能为空")
+	}
+	if strings.TrimSpace(plan.Config.OutputDir) == "" {
+		return Summary{}, errors.New("计划缺少输出目录")
+	}
+	if !plan.Config.TransferMode.valid() {
+		return Summary{}, fmt.Errorf("未知传输方式: %d", plan.Config.TransferMode)
+	}
+	if err := EnsureDir(plan.Config.OutputDir); err != nil {
+		return Summary{}, err
+	}
+	logStaleTempFiles(plan.Config.OutputDir, onEvent)
+
+	journal, err := newJournal(plan.Config)
+	if err != nil {
+		return Summary{}, err
+	}
+	state, err := openStateStore(plan.Config)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	sum := Summary{FoundPDF: plan.Summary.FoundPDF, Failed: plan.Summary.Failed}
+	for _, op := range plan.Ops {
+		if err := ctx.Err(); err != nil {
+			return sum, finishJournal(journal, finishState(state, canceledError(err)))
+		}
+		if op.Action != PlanWrite {
+			continue
+		}
+		err := plan.checkTarget(op)
+		if err == nil {
+			err = applyPlannedWrite(op, plan.Config.TransferMode, journal, state)
+		}
+		if err != nil {
+			sum.Failed++
+			onEvent(Event{Kind: EventFailed, Source: op.Source, Err: err, Summary: sum})
+			continue
+		}
+		sum.Succeeded++
+		onEvent(Event{Kind: EventWritten, Source: op.Source, OutputPath: op.Target, Summary: sum})
+	}
+	return sum, finishJournal(journal, finishState(state, nil))
+}
+
+func (p Plan) checkTarget(op PlannedOp) error {
+	target, err := absClean(op.Target)
+	if err != nil {
+		return fmt.Errorf("目标路径无效: %w", err)
+	}
+	if p.Config.TransferMode == TransferRenameInPlace && len(op.Source.Entries) == 0 {
+		if sameDir(filepath.Dir(target), filepath.Dir(op.Source.FilePath)) && isChildDir(op.Source.FilePath, p.Config.InputDir) {
+			return nil
+		}
+	}
+	outDir, err := absClean(p.Config.OutputDir)
+	if err != nil {
+		return fmt.Errorf("输出目录无效: %w", err)
+	}
+	if !isChildDir(target, outDir) {
+		return fmt.Errorf("目标路径不在输出目录内，拒绝写入: %s", op.Target)
+	}
+	return nil
+}
+
+func applyPlannedWrite(op PlannedOp, mode TransferMode, journal *Journal, state *stateStore) error {
+	pdfBytes, err := ReadSourceBytes(op.Source)
+	if err != nil {
+		return fmt.Errorf("读取源文件失败: %w", err)
+	}
+	if op.SHA256 != "" && sha256Hex(pdfBytes) != op.SHA256 {
+		return errors.New("源文件内容自生成计划后已改变")
+	}
+
+	var stateKey string
+	if state != nil {
+		stateKey, _ = sourceStateKey(op.Source, pdfBytes)
+	}
+	dir := filepath.Dir(op.Target)
+	if err := EnsureDir(dir); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	_, moved, err := placeFile(mode, op.Source, dir, fixedPath(op.Target), pdfBytes)
+	if err != nil {
+		return err
+	}
+	journal.record(op.Source, op.Target, pdfBytes, moved)
+	if stateKey != "" {
+		state.record(stateKey, op.Source, op.Target, journal.RunID)
+	}
+	return nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -4,9 +4,18 @@ import (
 	"TrainTicketsTool/internal/invoice"
 	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime/quotedprintable"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 const (
@@ -187,10 +196,1051 @@ func TestRun_DedupSameNamedPDFInZip(t *testing.T) {
 	}
 }
 
+func TestRun_DedupBySHA256(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	pdfA := buildPlainPDF(xbrlForProcessor)
+	pdfB := append(buildPlainPDF(xbrlForProcessor), []byte("%other\n")...)
+	if err := writeZipWithEntries(filepath.Join(inDir, "1.zip"), []zipEntry{{name: "invoice.pdf", bytes: pdfA}}); err != nil {
+		t.Fatalf("write zip 1: %v", err)
+	}
+	if err := writeZipWithEntries(filepath.Join(inDir, "2.zip"), []zipEntry{{name: "invoice.pdf", bytes: pdfB}}); err != nil {
+		t.Fatalf("write zip 2: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inDir, "3.pdf"), pdfA, defaultFileMode); err != nil {
+		t.Fatalf("write 3: %v", err)
+	}
+
+	logs := newLogCollector()
+	sum, err := Run(Config{
+		InputDir:  inDir,
+		OutputDir: outDir,
+		DateField: invoice.DateFieldTravel,
+		DedupMode: DedupBySHA256,
+	}, logs.Add)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if sum.FoundPDF != 2 || sum.Succeeded != 2 || sum.Failed != 0 {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+	if !logs.Contains("SKIP:", filepath.Join(inDir, "1.zip")+"!invoice.pdf") {
+		t.Fatalf("expected SKIP naming the earlier source, logs=%v", logs.lines)
+	}
+}
+
+func TestRun_DedupByInvoiceNumber(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	xbrl := `<xbrl xmlns:rai="urn:rai"><rai:EInvoiceNumber>26419000000123456789</rai:EInvoiceNumber>` + xbrlForProcessor[len(`<xbrl xmlns:rai="urn:rai">`):]
+	if err := os.WriteFile(filepath.Join(inDir, "a.pdf"), buildPlainPDF(xbrl), defaultFileMode); err != nil {
+		t.Fatalf("write a: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inDir, "b.pdf"), append(buildPlainPDF(xbrl), '\n'), defaultFileMode); err != nil {
+		t.Fatalf("write b: %v", err)
+	}
+
+	logs := newLogCollector()
+	sum, err := Run(Config{
+		InputDir:  inDir,
+		OutputDir: outDir,
+		DateField: invoice.DateFieldTravel,
+		DedupMode: DedupByInvoiceNumber,
+	}, logs.Add)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if sum.FoundPDF != 1 || sum.Succeeded != 1 || sum.Failed != 0 {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+	if !logs.Contains("SKIP:", "26419000000123456789", filepath.Join(inDir, "a.pdf")) {
+		t.Fatalf("expected SKIP naming invoice number and earlier source, logs=%v", logs.lines)
+	}
+}
+
+func TestRunWithEvents_ReportsKindsAndRunningCounts(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	pdfBytes := buildPlainPDF(xbrlForProcessor)
+	if err := writeZipWithEntries(filepath.Join(inDir, "a.zip"), []zipEntry{
+		{name: "x/same.pdf", bytes: pdfBytes},
+		{name: "y/same.pdf", bytes: pdfBytes},
+		{name: "bad.pdf", bytes: []byte("%PDF-1.7\n")},
+	}); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+
+	var events []Event
+	sum, err := RunWithEvents(context.Background(), Config{
+		InputDir:  inDir,
+		OutputDir: outDir,
+		DateField: invoice.DateFieldTravel,
+	}, func(e Event) { events = append(events, e) })
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	ok := events[0]
+	if ok.Kind != EventWritten || ok.Info == nil || ok.Info.TrainNumber != "" || ok.Info.DepartureStation != "郑州东" {
+		t.Fatalf("unexpected ok event: %+v", ok)
+	}
+	if got := ok.Source.String(); got != filepath.Join(inDir, "a.zip")+"!x/same.pdf" {
+		t.Fatalf("ok source=%q", got)
+	}
+	if ok.Summary.Succeeded != 1 {
+		t.Fatalf("ok running summary: %+v", ok.Summary)
+	}
+	if events[1].Kind != EventSkipped || events[2].Kind != EventFailed || events[2].Err == nil {
+		t.Fatalf("unexpected events: %+v", events[1:])
+	}
+	if events[2].Summary != sum {
+		t.Fatalf("last running summary %+v != final %+v", events[2].Summary, sum)
+	}
+}
+
+func TestRunWithEvents_PanicFailsOnlyThatFile(t *testing.T) {
+	inDir := t.TempDir()
+	pdf := buildPlainPDF(xbrlForProcessor)
+	for _, name := range []string{"a.pdf", "bad.pdf"} {
+		if err := os.WriteFile(filepath.Join(inDir, name), pdf, defaultFileMode); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	orig := extractInvoiceInfo
+	defer func() { extractInvoiceInfo = orig }()
+	extractInvoiceInfo = func(ctx context.Context, name string, b []byte, passwords []string) (invoice.InvoiceInfo, error) {
+		if name == "bad.pdf" {
+			panic("boom")
+		}
+		return orig(ctx, name, b, passwords)
+	}
+
+	for _, jobs := range []int{1, 4} {
+		logs := newLogCollector()
+		sum, err := Run(Config{InputDir: inDir, OutputDir: t.TempDir(), DateField: invoice.DateFieldTravel, Concurrency: jobs}, logs.Add)
+		if err != nil {
+			t.Fatalf("jobs=%d: Run error: %v", jobs, err)
+		}
+		if sum.Succeeded != 1 || sum.Failed != 1 || !logs.Contains("ERR:", "bad.pdf", "boom") {
+			t.Fatalf("jobs=%d: summary=%+v logs=%v", jobs, sum, logs.lines)
+		}
+	}
+}
+
+func TestRunWithEvents_CancelReturnsPartialSummary(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	for _, name := range []string{"a.pdf", "b.pdf", "c.pdf"} {
+		if err := os.WriteFile(filepath.Join(inDir, name), buildPlainPDF(xbrlForProcessor), defaultFileMode); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sum, err := RunWithEvents(ctx, Config{
+		InputDir:  inDir,
+		OutputDir: outDir,
+		DateField: invoice.DateFieldTravel,
+	}, func(e Event) {
+		if e.Kind == EventWritten {
+			cancel()
+		}
+	})
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if sum.FoundPDF != 1 || sum.Succeeded != 1 || sum.Failed != 0 {
+		t.Fatalf("unexpected partial summary: %+v", sum)
+	}
+}
+
+func TestRunWithEvents_ConcurrencyIsDeterministic(t *testing.T) {
+	inDir := t.TempDir()
+	pdfBytes := buildPlainPDF(xbrlForProcessor)
+	for i := 0; i < 12; i++ {
+		name := filepath.Join(inDir, fmt.Sprintf("%02d.pdf", i))
+		if err := os.WriteFile(name, append(pdfBytes, byte('a'+i)), defaultFileMode); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(inDir, "bad.pdf"), []byte("%PDF-1.7\n"), defaultFileMode); err != nil {
+		t.Fatalf("write bad: %v", err)
+	}
+	if err := writeZipWithEntries(filepath.Join(inDir, "z.zip"), []zipEntry{
+		{name: "00.pdf", bytes: pdfBytes},
+		{name: "new.pdf", bytes: pdfBytes},
+	}); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+
+	runOnce := func(concurrency int) []string {
+		var lines []string
+		_, err := RunWithEvents(context.Background(), Config{
+			InputDir:    inDir,
+			OutputDir:   t.TempDir(),
+			DateField:   invoice.DateFieldTravel,
+			Concurrency: concurrency,
+		}, func(e Event) {
+			lines = append(lines, fmt.Sprintf("%s %s %s", e.Kind, e.Source, filepath.Base(e.OutputPath)))
+		})
+		if err != nil {
+			t.Fatalf("Run error: %v", err)
+		}
+		return lines
+	}
+
+	serial := runOnce(1)
+	for _, n := range []int{2, 8} {
+		got := runOnce(n)
+		if strings.Join(got, "\n") != strings.Join(serial, "\n") {
+			t.Fatalf("concurrency=%d differs:\n%s\nserial:\n%s", n, strings.Join(got, "\n"), strings.Join(serial, "\n"))
+		}
+	}
+}
+
+func TestUndoRun_RemovesOnlyUnchangedOutputs(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	pdfBytes := buildPlainPDF(xbrlForProcessor)
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		if err := os.WriteFile(filepath.Join(inDir, name), pdfBytes, defaultFileMode); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	emptyOut := t.TempDir()
+	if _, err := Run(Config{InputDir: t.TempDir(), OutputDir: emptyOut, DateField: invoice.DateFieldTravel}, newLogCollector().Add); err != nil {
+		t.Fatalf("empty run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(emptyOut, JournalDirName)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("a run without outputs must not leave a journal: %v", err)
+	}
+
+	cfg := Config{InputDir: inDir, OutputDir: outDir, DateField: invoice.DateFieldTravel, Concurrency: 1}
+	written := 0
+	if _, err := RunWithEvents(context.Background(), cfg, func(e Event) {
+		if e.Kind != EventWritten {
+			return
+		}
+		written++
+		j, err := LoadJournal(outDir, LatestRunID)
+		if err != nil || len(j.Entries) != written {
+			t.Errorf("journal must be flushed after each write: entries=%d written=%d err=%v", len(j.Entries), written, err)
+		}
+	}); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	first := filepath.Join(outDir, "2026-02-24-郑州东-三门峡南.pdf")
+	second := filepath.Join(outDir, "2026-02-24-郑州东-三门峡南-2.pdf")
+	if err := os.WriteFile(second, []byte("edited"), defaultFileMode); err != nil {
+		t.Fatalf("edit output: %v", err)
+	}
+
+	logs := newLogCollector()
+	sum, err := UndoRun(outDir, LatestRunID, logs.Add)
+	if err != nil {
+		t.Fatalf("UndoRun error: %v", err)
+	}
+	if sum.FoundPDF != 2 || sum.Succeeded != 1 || sum.Failed != 1 {
+		t.Fatalf("unexpected undo summary: %+v logs=%v", sum, logs.lines)
+	}
+	if _, err := os.Stat(first); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %s removed, stat err=%v", first, err)
+	}
+	if b, err := os.ReadFile(second); err != nil || string(b) != "edited" {
+		t.Fatalf("modified output must be kept: %q %v", b, err)
+	}
+
+	if err := os.Remove(second); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if sum, err := UndoRun(outDir, LatestRunID, logs.Add); err != nil || sum.Failed != 0 {
+		t.Fatalf("second undo sum=%+v err=%v", sum, err)
+	}
+	journals, err := ListJournals(outDir)
+	if err != nil || len(journals) != 1 || journals[0].UndoneAt == nil {
+		t.Fatalf("expected one undone journal, got %+v err=%v", journals, err)
+	}
+	if _, err := UndoRun(outDir, journals[0].RunID, logs.Add); err == nil {
+		t.Fatalf("expected error when undoing twice")
+	}
+}
+
+func TestRun_EmlAttachments(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	f, err := zw.Create("inner.pdf")
+	if err != nil {
+		t.Fatalf("zip create: %v", err)
+	}
+	if _, err := f.Write(buildPlainPDF(xbrlForProcessor)); err != nil {
+		t.Fatalf("zip write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+
+	eml := buildMultipartMail([]mailPart{
+		{header: "Content-Type: text/plain; charset=utf-8", body: "您好"},
+		{
+			header: "Content-Type: application/zip\r\nContent-Transfer-Encoding: base64\r\n" +
+				`Content-Disposition: attachment; filename="=?UTF-8?B?` + base64.StdEncoding.EncodeToString([]byte("电子发票.zip")) + `?="`,
+			body: base64.StdEncoding.EncodeToString(zipBuf.Bytes()),
+		},
+	})
+	emlPath := filepath.Join(inDir, "mail.eml")
+	if err := os.WriteFile(emlPath, eml, defaultFileMode); err != nil {
+		t.Fatalf("write eml: %v", err)
+	}
+
+	plan, err := BuildPlan(Config{
+		InputDir:  inDir,
+		OutputDir: outDir,
+		DateField: invoice.DateFieldTravel,
+	}, newLogCollector().Add)
+	if err != nil {
+		t.Fatalf("BuildPlan error: %v", err)
+	}
+	if len(plan.Ops) != 1 || plan.Ops[0].Action != PlanWrite {
+		t.Fatalf("unexpected plan: %+v", plan.Ops)
+	}
+	if got, want := plan.Ops[0].Source.String(), emlPath+"!电子发票.zip!inner.pdf"; got != want {
+		t.Fatalf("source=%q want %q", got, want)
+	}
+
+	sum, err := ApplyPlan(plan, newLogCollector().Add)
+	if err != nil || sum.Succeeded != 1 {
+		t.Fatalf("ApplyPlan sum=%+v err=%v", sum, err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "2026-02-24-郑州东-三门峡南.pdf")); err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+}
+
+func TestRun_MboxQuotedPrintablePDF(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	var qp bytes.Buffer
+	w := quotedprintable.NewWriter(&qp)
+	if _, err := w.Write(buildPlainPDF(xbrlForProcessor)); err != nil {
+		t.Fatalf("qp write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("qp close: %v", err)
+	}
+
+	msg := buildMultipartMail([]mailPart{{
+		header: "Content-Type: application/pdf; name=\"a.pdf\"\r\nContent-Transfer-Encoding: quoted-printable",
+		body:   qp.String(),
+	}})
+	mbox := "From a@example.com Mon Feb 23 10:00:00 2026\n" + string(msg) + "\n" +
+		"From b@example.com Mon Feb 23 11:00:00 2026\n" + string(msg) + "\n"
+	if err := os.WriteFile(filepath.Join(inDir, "inbox.mbox"), []byte(mbox), defaultFileMode); err != nil {
+		t.Fatalf("write mbox: %v", err)
+	}
+
+	logs := newLogCollector()
+	sum, err := Run(Config{
+		InputDir:  inDir,
+		OutputDir: outDir,
+		DateField: invoice.DateFieldTravel,
+		DedupMode: DedupBySHA256,
+	}, logs.Add)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if sum.FoundPDF != 1 || sum.Succeeded != 1 || sum.Failed != 0 {
+		t.Fatalf("unexpected summary: %+v logs=%v", sum, logs.lines)
+	}
+	if !logs.Contains("SKIP:", "inbox.mbox!message-2.eml!a.pdf", "inbox.mbox!message-1.eml!a.pdf") {
+		t.Fatalf("expected SKIP for second message, logs=%v", logs.lines)
+	}
+}
+
+func TestRun_OFDFileAndZipEntry(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	ofdPath := filepath.Join(inDir, "a.ofd")
+	if err := writeZipWithEntries(ofdPath, ofdEntries(xbrlForProcessor)); err != nil {
+		t.Fatalf("write ofd: %v", err)
+	}
+	ofdBytes, err := os.ReadFile(ofdPath)
+	if err != nil {
+		t.Fatalf("read ofd: %v", err)
+	}
+	laterXbrl := strings.Replace(xbrlForProcessor, "2026-02-24", "2026-03-01", 1)
+	var nested bytes.Buffer
+	zw := zip.NewWriter(&nested)
+	for _, e := range ofdEntries(laterXbrl) {
+		w, _ := zw.Create(e.name)
+		w.Write(e.bytes)
+	}
+	zw.Close()
+	if err := writeZipWithEntries(filepath.Join(inDir, "batch.zip"), []zipEntry{{name: "b.ofd", bytes: nested.Bytes()}}); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+
+	sum, err := Run(Config{InputDir: inDir, OutputDir: outDir, DateField: invoice.DateFieldTravel}, newLogCollector().Add)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if sum.Succeeded != 2 || sum.Failed != 0 {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+	for _, name := range []string{"2026-02-24-郑州东-三门峡南.ofd", "2026-03-01-郑州东-三门峡南.ofd"} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Fatalf("expected output file %s: %v", name, err)
+		}
+	}
+	got, err := os.ReadFile(filepath.Join(outDir, "2026-02-24-郑州东-三门峡南.ofd"))
+	if err != nil || !bytes.Equal(got, ofdBytes) {
+		t.Fatalf("ofd output differs from input: %v", err)
+	}
+}
+
+func TestRun_TextLayerFallbackAcceptOrReject(t *testing.T) {
+	inDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inDir, "old.pdf"), buildTextOnlyPDF(), defaultFileMode); err != nil {
+		t.Fatalf("write input pdf: %v", err)
+	}
+
+	for _, reject := range []bool{false, true} {
+		outDir := t.TempDir()
+		logs := newLogCollector()
+		sum, err := Run(Config{InputDir: inDir, OutputDir: outDir, DateField: invoice.DateFieldTravel, RejectLowConfidence: reject}, logs.Add)
+		if err != nil {
+			t.Fatalf("Run error: %v", err)
+		}
+		_, statErr := os.Stat(filepath.Join(outDir, "2026-02-24-郑州东-三门峡南.pdf"))
+		if reject {
+			if sum.Failed != 1 || statErr == nil || !logs.Contains("ERR:", "低可信度") {
+				t.Fatalf("reject: summary=%+v stat=%v logs=%v", sum, statErr, logs.lines)
+			}
+			continue
+		}
+		if sum.Succeeded != 1 || statErr != nil || !logs.Contains("OK:", "文本层识别") {
+			t.Fatalf("accept: summary=%+v stat=%v logs=%v", sum, statErr, logs.lines)
+		}
+	}
+}
+
+func TestRun_IncrementalSkipsProcessedSources(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inDir, "a.pdf"), buildPlainPDF(xbrlForProcessor), defaultFileMode); err != nil {
+		t.Fatalf("write input pdf: %v", err)
+	}
+	laterXbrl := strings.Replace(xbrlForProcessor, "2026-02-24", "2026-03-01", 1)
+	if err := writeZipWithEntries(filepath.Join(inDir, "batch.zip"), []zipEntry{{name: "b.pdf", bytes: buildPlainPDF(laterXbrl)}}); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+	cfg := Config{InputDir: inDir, OutputDir: outDir, DateField: invoice.DateFieldTravel, Incremental: true}
+	first := filepath.Join(outDir, "2026-02-24-郑州东-三门峡南.pdf")
+
+	defer func(n int) { stateSaveBatch = n }(stateSaveBatch)
+	stateSaveBatch = 1
+	written := 0
+	sum, err := RunWithEvents(context.Background(), cfg, func(e Event) {
+		if e.Kind != EventWritten {
+			return
+		}
+		written++
+		if st, err := LoadState(outDir); err != nil || len(st.Entries) != written {
+			t.Errorf("state must be saved as each write completes: entries=%d written=%d err=%v", len(st.Entries), written, err)
+		}
+	})
+	if err != nil || sum.Succeeded != 2 {
+		t.Fatalf("first run: summary=%+v err=%v", sum, err)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(outDir, "*.tmp")); len(tmps) > 0 {
+		t.Fatalf("state temp files left behind: %v", tmps)
+	}
+	st, err := LoadState(outDir)
+	if err != nil || len(st.Entries) != 2 {
+		t.Fatalf("state=%+v err=%v", st, err)
+	}
+
+	logs := newLogCollector()
+	sum, err = Run(cfg, logs.Add)
+	if err != nil || sum.Succeeded != 0 || sum.Failed != 0 {
+		t.Fatalf("second run: summary=%+v err=%v", sum, err)
+	}
+	if !logs.Contains("SKIP:", "已处理过", first, "a.pdf") || !logs.Contains("SKIP:", "已处理过", "batch.zip!b.pdf") {
+		t.Fatalf("expected already-processed skips, logs=%v", logs.lines)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "2026-02-24-郑州东-三门峡南-2.pdf")); !os.IsNotExist(err) {
+		t.Fatalf("second run must not write another copy: %v", err)
+	}
+
+	if err := os.Remove(first); err != nil {
+		t.Fatalf("remove output: %v", err)
+	}
+	if sum, err := Run(cfg, newLogCollector().Add); err != nil || sum.Succeeded != 1 {
+		t.Fatalf("run after removing output: summary=%+v err=%v", sum, err)
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Fatalf("removed output should be written again: %v", err)
+	}
+}
+
+func TestWatch_ProcessesNewFilesAsTheyArrive(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inDir, "a.pdf"), buildPlainPDF(xbrlForProcessor), defaultFileMode); err != nil {
+		t.Fatalf("write input pdf: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan Event, 64)
+	done := make(chan struct{})
+	var sum Summary
+	var watchErr error
+	go func() {
+		defer close(done)
+		sum, watchErr = Watch(ctx, Config{InputDir: inDir, OutputDir: outDir, DateField: invoice.DateFieldTravel}, 10*time.Millisecond, func(e Event) {
+			events <- e
+		})
+	}()
+	waitWritten := func(name string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case e := <-events:
+				if e.Kind == EventFailed {
+					t.Fatalf("unexpected failure: %s", e.LogLine())
+				}
+				if e.Kind == EventWritten && filepath.Base(e.OutputPath) == name {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s", name)
+			}
+		}
+	}
+	waitWritten("2026-02-24-郑州东-三门峡南.pdf")
+
+	laterXbrl := strings.Replace(xbrlForProcessor, "2026-02-24", "2026-03-01", 1)
+	tmpZip := filepath.Join(t.TempDir(), "batch.zip")
+	if err := writeZipWithEntries(tmpZip, []zipEntry{{name: "b.pdf", bytes: buildPlainPDF(laterXbrl)}}); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+	if err := os.Rename(tmpZip, filepath.Join(inDir, "batch.zip")); err != nil {
+		t.Fatalf("move zip into input: %v", err)
+	}
+	waitWritten("2026-03-01-郑州东-三门峡南.pdf")
+
+	if err := os.RemoveAll(inDir); err != nil {
+		t.Fatalf("remove input dir: %v", err)
+	}
+	for failed := false; !failed; {
+		select {
+		case e := <-events:
+			if e.Kind == EventFailed && !strings.Contains(e.LogLine(), "访问失败") {
+				t.Fatalf("expected walk error event, got %s", e.LogLine())
+			}
+			failed = e.Kind == EventFailed
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for walk error event")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	<-done
+	if !errors.Is(watchErr, ErrCanceled) || sum.Succeeded != 2 || sum.Failed != 1 {
+		t.Fatalf("walk errors must be reported once: summary=%+v err=%v", sum, watchErr)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "2026-02-24-郑州东-三门峡南-2.pdf")); !os.IsNotExist(err) {
+		t.Fatalf("files must be processed only once: %v", err)
+	}
+}
+
+func TestRun_CustomNameTemplate(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(inDir, "a.pdf"), buildPlainPDF(xbrlForProcessor), defaultFileMode); err != nil {
+		t.Fatalf("write a: %v", err)
+	}
+
+	logs := newLogCollector()
+	sum, err := Run(Config{
+		InputDir:     inDir,
+		OutputDir:    outDir,
+		DateField:    invoice.DateFieldTravel,
+		NameTemplate: "{issueDate:yyyyMMdd}_{from}至{to}_{date:yyyy年M月d日}",
+	}, logs.Add)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if sum.Succeeded != 1 || sum.Failed != 0 {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+
+	want := filepath.Join(outDir, "20260228_郑州东至三门峡南_2026年2月24日.pdf")
+	if _, err := os.Stat(want); err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+}
+
+func TestRun_DirTemplateOrganizesIntoSubfolders(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inDir, "a.pdf"), buildPlainPDF(xbrlForProcessor), defaultFileMode); err != nil {
+		t.Fatalf("write a: %v", err)
+	}
+	escaping := strings.Replace(xbrlForProcessor, "<rai:DepartureStation>郑州东<", "<rai:DepartureStation>..<", 1)
+	if err := os.WriteFile(filepath.Join(inDir, "b.pdf"), buildPlainPDF(escaping), defaultFileMode); err != nil {
+		t.Fatalf("write b: %v", err)
+	}
+
+	logs := newLogCollector()
+	sum, err := Run(Config{
+		InputDir:     inDir,
+		OutputDir:    outDir,
+		DateField:    invoice.DateFieldTravel,
+		NameTemplate: "{date}-{to}.pdf",
+		DirTemplate:  `{date:yyyy}\{date:yyyy-MM}/{from}`,
+	}, logs.Add)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if sum.Succeeded != 1 || sum.Failed != 1 || !logs.Contains("ERR:", "b.pdf", "{from}") {
+		t.Fatalf("summary=%+v logs=%v", sum, logs.lines)
+	}
+	if escaped, _ := filepath.Glob(filepath.Join(filepath.Dir(outDir), "*.pdf")); len(escaped) > 0 {
+		t.Fatalf("output escaped the output directory: %v", escaped)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "2026", "2026-02", "郑州东", "2026-02-24-三门峡南.pdf")); err != nil {
+		t.Fatalf("expected output in sub-folder: %v", err)
+	}
+
+	for _, tmpl := range []string{"{date:yyyy}//x", "a/<b>", "{unknown}/x", "../.."} {
+		if err := ValidateDirTemplate(tmpl); err == nil {
+			t.Fatalf("expected error for dir template %q", tmpl)
+		}
+	}
+}
+
+func TestRun_TransferModes(t *testing.T) {
+	const outName = "2026-02-24-郑州东-三门峡南.pdf"
+	pdf := buildPlainPDF(xbrlForProcessor)
+
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inDir, "a.pdf"), pdf, defaultFileMode); err != nil {
+		t.Fatalf("write a: %v", err)
+	}
+	if err := writeNestedZipWithPDF(filepath.Join(inDir, "b.zip"), "inner.zip", "x.pdf", pdf); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+	sum, err := Run(Config{InputDir: inDir, OutputDir: outDir, DateField: invoice.DateFieldTravel, TransferMode: TransferMove}, newLogCollector().Add)
+	if err != nil || sum.Succeeded != 2 {
+		t.Fatalf("move: summary=%+v err=%v", sum, err)
+	}
+	if _, err := os.Stat(filepath.Join(inDir, "a.pdf")); !os.IsNotExist(err) {
+		t.Fatalf("moved source should be gone: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(inDir, "b.zip")); err != nil {
+		t.Fatalf("zip source must stay: %v", err)
+	}
+	logs := newLogCollector()
+	if sum, err := UndoRun(outDir, LatestRunID, logs.Add); err != nil || sum.Succeeded != 2 || !logs.Contains("DEL:", "已移回原位置") {
+		t.Fatalf("undo: summary=%+v err=%v logs=%v", sum, err, logs.lines)
+	}
+	if b, err := os.ReadFile(filepath.Join(inDir, "a.pdf")); err != nil || !bytes.Equal(b, pdf) {
+		t.Fatalf("undo should move the source back: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(outDir, "*.pdf")); len(files) != 0 {
+		t.Fatalf("undo left outputs: %v", files)
+	}
+
+	linkOut := t.TempDir()
+	if _, err := Run(Config{InputDir: inDir, OutputDir: linkOut, DateField: invoice.DateFieldTravel, TransferMode: TransferHardLink}, newLogCollector().Add); err != nil {
+		t.Fatalf("hardlink: %v", err)
+	}
+	srcInfo, err := os.Stat(filepath.Join(inDir, "a.pdf"))
+	if err != nil {
+		t.Fatalf("hardlink source: %v", err)
+	}
+	linkInfo, err := os.Stat(filepath.Join(linkOut, outName))
+	if err != nil || !os.SameFile(srcInfo, linkInfo) {
+		t.Fatalf("expected hard link to source: %v", err)
+	}
+
+	if _, err := Run(Config{InputDir: inDir, OutputDir: inDir, DateField: invoice.DateFieldTravel}, newLogCollector().Add); err == nil {
+		t.Fatalf("copy into the input dir should be rejected")
+	}
+	if _, err := Run(Config{InputDir: inDir, OutputDir: inDir, DateField: invoice.DateFieldTravel, DirTemplate: "{date:yyyy}", TransferMode: TransferRenameInPlace}, newLogCollector().Add); !errors.Is(err, errRenameWithDirTemplate) {
+		t.Fatalf("rename with a dir template should be rejected, got %v", err)
+	}
+	variant := append(append([]byte{}, pdf...), "\n%variant\n"...)
+	if err := os.WriteFile(filepath.Join(inDir, "c.pdf"), variant, defaultFileMode); err != nil {
+		t.Fatalf("write c: %v", err)
+	}
+	var firstRun map[string][]byte
+	for i, want := range []int{2, 0} {
+		logs := newLogCollector()
+		sum, err := Run(Config{InputDir: inDir, OutputDir: inDir, DateField: invoice.DateFieldTravel, DedupMode: DedupBySHA256, TransferMode: TransferRenameInPlace}, logs.Add)
+		if err != nil || sum.Succeeded != want || sum.Failed != 0 {
+			t.Fatalf("rename run %d: summary=%+v err=%v logs=%v", i+1, sum, err, logs.lines)
+		}
+		if i == 1 && !logs.Contains("SKIP", "无需重命名") {
+			t.Fatalf("second rename run should skip files: %v", logs.lines)
+		}
+		files, _ := filepath.Glob(filepath.Join(inDir, "*.pdf"))
+		got := make(map[string][]byte, len(files))
+		for _, f := range files {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatalf("read %s: %v", f, err)
+			}
+			got[filepath.Base(f)] = b
+		}
+		if len(got) != 2 || got[outName] == nil || got["2026-02-24-郑州东-三门峡南-2.pdf"] == nil {
+			t.Fatalf("rename run %d: unexpected files %v", i+1, files)
+		}
+		if firstRun != nil {
+			for name, b := range firstRun {
+				if !bytes.Equal(got[name], b) {
+					t.Fatalf("suffixed names must be stable across runs: %s changed", name)
+				}
+			}
+		}
+		firstRun = got
+	}
+}
+
+func TestWritePDF_AtomicNoReplaceRetriesNextSuffix(t *testing.T) {
+	dir := t.TempDir()
+	taken := filepath.Join(dir, "a.pdf")
+	calls := 0
+	pick := func() (string, error) {
+		calls++
+		if calls == 1 {
+			if err := os.WriteFile(taken, []byte("other"), defaultFileMode); err != nil {
+				return "", err
+			}
+			return taken, nil
+		}
+		return UniqueOutputPath(dir, "a.pdf")
+	}
+	got, err := writeFileAtomic(dir, pick, []byte("mine"))
+	if err != nil || filepath.Base(got) != "a-2.pdf" {
+		t.Fatalf("got=%q err=%v", got, err)
+	}
+	if b, _ := os.ReadFile(taken); string(b) != "other" {
+		t.Fatalf("existing file was replaced: %q", b)
+	}
+
+	const writers = 8
+	paths := make([]string, writers)
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = WritePDF(dir, "b.pdf", []byte(fmt.Sprintf("pdf-%d", i)))
+		}(i)
+	}
+	wg.Wait()
+	seen := make(map[string]bool)
+	for i, p := range paths {
+		if errs[i] != nil || seen[p] {
+			t.Fatalf("writer %d: path=%q err=%v", i, p, errs[i])
+		}
+		seen[p] = true
+		if b, _ := os.ReadFile(p); string(b) != fmt.Sprintf("pdf-%d", i) {
+			t.Fatalf("writer %d: content %q in %s", i, b, p)
+		}
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, tempFilePattern)); len(tmps) > 0 {
+		t.Fatalf("temp files left behind: %v", tmps)
+	}
+
+	src := filepath.Join(dir, "src.pdf")
+	if err := os.WriteFile(src, []byte("src"), defaultFileMode); err != nil {
+		t.Fatalf("write src: %v", err)
+	}
+	if err := renameNoReplace(src, taken); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("renameNoReplace over an existing file: %v", err)
+	}
+	if b, _ := os.ReadFile(taken); string(b) != "other" {
+		t.Fatalf("renameNoReplace replaced the target: %q", b)
+	}
+	for _, errno := range []syscall.Errno{syscall.EINVAL, syscall.ENOTSUP, syscall.ENOSYS} {
+		unsupported := func(from string, to string) error {
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: errno}
+		}
+		if err := renameOrLink(unsupported, src, taken); !errors.Is(err, os.ErrExist) {
+			t.Fatalf("%v fallback over an existing file: %v", errno, err)
+		}
+		moved := filepath.Join(dir, "moved.pdf")
+		if err := renameOrLink(unsupported, src, moved); err != nil {
+			t.Fatalf("%v should fall back to link and remove: %v", errno, err)
+		}
+		if _, err := os.Stat(src); !os.IsNotExist(err) {
+			t.Fatalf("%v fallback left the source behind: %v", errno, err)
+		}
+		if err := os.Rename(moved, src); err != nil {
+			t.Fatalf("restore src: %v", err)
+		}
+	}
+	if b, _ := os.ReadFile(taken); string(b) != "other" {
+		t.Fatalf("fallback replaced the target: %q", b)
+	}
+
+	stale := filepath.Join(dir, ".invoice-1.tmp")
+	fresh := filepath.Join(dir, ".invoice-2.tmp")
+	for _, p := range []string{stale, fresh} {
+		if err := os.WriteFile(p, buildPlainPDF(xbrlForProcessor), defaultFileMode); err != nil {
+			t.Fatalf("write temp: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * staleTempAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if n := removeStaleTempFiles(dir); n != 1 {
+		t.Fatalf("removed %d stale temp files, want 1", n)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatalf("fresh temp file of a concurrent writer must survive: %v", err)
+	}
+	if !IsTempFile(fresh) || IsTempFile(taken) {
+		t.Fatalf("IsTempFile misclassified %s / %s", fresh, taken)
+	}
+}
+
+func TestRun_InvalidNameTemplateWritesNothing(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	if err := os.WriteFile(filepath.Join(inDir, "a.pdf"), buildPlainPDF(xbrlForProcessor), defaultFileMode); err != nil {
+		t.Fatalf("write a: %v", err)
+	}
+
+	for _, tmpl := range []string{"{unknown}", "{from", "{from}/{to}", "{date:yyyy/MM}", "{from:yyyy}", "a:{to}"} {
+		_, err := Run(Config{
+			InputDir:     inDir,
+			OutputDir:    outDir,
+			DateField:    invoice.DateFieldTravel,
+			NameTemplate: tmpl,
+		}, newLogCollector().Add)
+		if err == nil {
+			t.Fatalf("expected error for template %q", tmpl)
+		}
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Fatalf("output dir should not be created: %v", err)
+	}
+}
+
+func TestBuildPlan_DoesNotWriteAndApplies(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	if err := os.WriteFile(filepath.Join(inDir, "a.pdf"), buildPlainPDF(xbrlForProcessor), defaultFileMode); err != nil {
+		t.Fatalf("write a: %v", err)
+	}
+	if err := writeNestedZipWithPDF(filepath.Join(inDir, "multi.zip"), "inner.zip", "x.pdf", buildPlainPDF(xbrlForProcessor)); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inDir, "bad.pdf"), []byte("%PDF-1.7\n"), defaultFileMode); err != nil {
+		t.Fatalf("write bad: %v", err)
+	}
+
+	plan, err := BuildPlan(Config{
+		InputDir:  inDir,
+		OutputDir: outDir,
+		DateField: invoice.DateFieldTravel,
+	}, newLogCollector().Add)
+	if err != nil {
+		t.Fatalf("BuildPlan error: %v", err)
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Fatalf("plan must not create output dir: %v", err)
+	}
+	if plan.Summary.FoundPDF != 3 || plan.Summary.Succeeded != 2 || plan.Summary.Failed != 1 {
+		t.Fatalf("unexpected plan summary: %+v", plan.Summary)
+	}
+
+	var targets []string
+	for _, op := range plan.Ops {
+		if op.Action == PlanWrite {
+			targets = append(targets, op.Target)
+		}
+	}
+	wantTargets := []string{
+		filepath.Join(outDir, "2026-02-24-郑州东-三门峡南.pdf"),
+		filepath.Join(outDir, "2026-02-24-郑州东-三门峡南-2.pdf"),
+	}
+	if len(targets) != len(wantTargets) || targets[0] != wantTargets[0] || targets[1] != wantTargets[1] {
+		t.Fatalf("targets=%v want=%v", targets, wantTargets)
+	}
+
+	sum, err := ApplyPlan(plan, newLogCollector().Add)
+	if err != nil {
+		t.Fatalf("ApplyPlan error: %v", err)
+	}
+	if sum.Succeeded != 2 || sum.Failed != 1 {
+		t.Fatalf("unexpected apply summary: %+v", sum)
+	}
+	for _, p := range wantTargets {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("expected output file: %v", err)
+		}
+	}
+
+	outside := filepath.Join(t.TempDir(), "escaped.pdf")
+	hostile := plan
+	hostile.Ops = []PlannedOp{}
+	for _, target := range []string{outside, filepath.Join(outDir, "..", "escaped.pdf")} {
+		for _, op := range plan.Ops {
+			if op.Action == PlanWrite {
+				op.Target = target
+				hostile.Ops = append(hostile.Ops, op)
+				break
+			}
+		}
+	}
+	logs := newLogCollector()
+	sum, err = ApplyPlan(hostile, logs.Add)
+	if err != nil || sum.Succeeded != 0 || sum.Failed != 3 || !logs.Contains("ERR:", "拒绝写入") {
+		t.Fatalf("hostile plan: summary=%+v err=%v logs=%v", sum, err, logs.lines)
+	}
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Fatalf("hostile plan wrote outside the output dir: %v", err)
+	}
+	if upper, lower := plannedPathKey(filepath.Join(outDir, "A.pdf")), plannedPathKey(filepath.Join(outDir, "a.pdf")); (upper == lower) != caseInsensitivePaths {
+		t.Fatalf("plannedPathKey case folding mismatch: %q vs %q", upper, lower)
+	}
+}
+
+func TestBuildPlan_DuplicateAndSeparatorNamesResolveExactly(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+	pdfFor := func(from, to string) []byte {
+		return buildPlainPDF(strings.NewReplacer("郑州东", from, "三门峡南", to).Replace(xbrlForProcessor))
+	}
+
+	zipPath := filepath.Join(inDir, "a!b%21.zip")
+	if err := writeZipWithEntries(zipPath, []zipEntry{
+		{name: "x.pdf", bytes: pdfFor("北京", "上海")},
+		{name: "x.pdf", bytes: pdfFor("广州", "深圳")},
+		{name: "x (2).pdf", bytes: pdfFor("成都", "重庆")},
+		{name: "y!z.pdf", bytes: pdfFor("西安", "兰州")},
+	}); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+	emlPath := filepath.Join(inDir, "c!d.eml")
+	attachment := func(b []byte) mailPart {
+		return mailPart{
+			header: "Content-Type: application/pdf\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"x.pdf\"",
+			body:   base64.StdEncoding.EncodeToString(b),
+		}
+	}
+	if err := os.WriteFile(emlPath, buildMultipartMail([]mailPart{attachment(pdfFor("杭州", "宁波")), attachment(pdfFor("南京", "苏州"))}), defaultFileMode); err != nil {
+		t.Fatalf("write eml: %v", err)
+	}
+
+	plan, err := BuildPlan(Config{InputDir: inDir, OutputDir: outDir, DateField: invoice.DateFieldTravel, DedupMode: DedupBySHA256}, newLogCollector().Add)
+	if err != nil {
+		t.Fatalf("BuildPlan error: %v", err)
+	}
+	want := map[string]string{
+		filepath.Join(inDir, "a%21b%2521.zip") + "!x.pdf":     "北京-上海",
+		filepath.Join(inDir, "a%21b%2521.zip") + "!x (3).pdf": "广州-深圳",
+		filepath.Join(inDir, "a%21b%2521.zip") + "!x (2).pdf": "成都-重庆",
+		filepath.Join(inDir, "a%21b%2521.zip") + "!y%21z.pdf": "西安-兰州",
+		filepath.Join(inDir, "c%21d.eml") + "!x.pdf":          "杭州-宁波",
+		filepath.Join(inDir, "c%21d.eml") + "!x (2).pdf":      "南京-苏州",
+	}
+	if len(plan.Ops) != len(want) {
+		t.Fatalf("unexpected plan: %+v", plan.Ops)
+	}
+	for _, op := range plan.Ops {
+		ref := op.Source.String()
+		route, ok := want[ref]
+		if !ok || op.Action != PlanWrite || !strings.HasSuffix(op.Target, route+".pdf") {
+			t.Fatalf("unexpected op %s -> %s (%s)", ref, op.Target, op.Action)
+		}
+		parsed := ParseSourceRef(ref)
+		if parsed.String() != ref || parsed.FilePath != op.Source.FilePath {
+			t.Fatalf("ParseSourceRef(%q) = %+v", ref, parsed)
+		}
+		b, err := ReadSourceBytes(parsed)
+		if err != nil {
+			t.Fatalf("ReadSourceBytes(%q): %v", ref, err)
+		}
+		if info, err := invoice.ExtractInvoiceInfoFromPDFBytes(b); err != nil || info.DepartureStation+"-"+info.DestinationStation != route {
+			t.Fatalf("%s resolved to %+v err=%v, want %s", ref, info, err, route)
+		}
+	}
+
+	sum, err := ApplyPlan(plan, newLogCollector().Add)
+	if err != nil || sum.Succeeded != len(want) || sum.Failed != 0 {
+		t.Fatalf("ApplyPlan sum=%+v err=%v", sum, err)
+	}
+}
+
 func buildPlainPDF(xbrl string) []byte {
 	return []byte("%PDF-1.7\nstream\n" + xbrl + "\nendstream\n%%EOF\n")
 }
 
+func buildTextOnlyPDF() []byte {
+	ucs2 := func(s string) string {
+		var sb strings.Builder
+		for _, r := range s {
+			fmt.Fprintf(&sb, "%04X", r)
+		}
+		return "<" + sb.String() + ">"
+	}
+	content := "BT /F1 10 Tf 50 700 Td " + ucs2("郑州东站 G1234 三门峡南站") + " Tj 0 -30 Td " + ucs2("2026年02月24日 08:15开") + " Tj ET"
+	objects := []string{
+		"<</Type/Catalog/Pages 2 0 R>>",
+		"<</Type/Pages/Kids[3 0 R]/Count 1>>",
+		"<</Type/Page/Parent 2 0 R/Resources<</Font<</F1 4 0 R>>>>/Contents 5 0 R>>",
+		"<</Type/Font/Subtype/Type0/BaseFont/STSong-Light/Encoding/UniGB-UCS2-H>>",
+		fmt.Sprintf("<</Length %d>>stream\n%s\nendstream", len(content), content),
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	b.WriteString("trailer\n<</Root 1 0 R>>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func ofdEntries(xbrl string) []zipEntry {
+	const ns = `xmlns:ofd="http://www.ofdspec.org/2016"`
+	return []zipEntry{
+		{name: "OFD.xml", bytes: []byte(`<ofd:OFD ` + ns + `><ofd:DocBody><ofd:DocRoot>Doc_0/Document.xml</ofd:DocRoot></ofd:DocBody></ofd:OFD>`)},
+		{name: "Doc_0/Document.xml", bytes: []byte(`<ofd:Document ` + ns + `><ofd:Attachments>Attachs/Attachments.xml</ofd:Attachments></ofd:Document>`)},
+		{name: "Doc_0/Attachs/Attachments.xml", bytes: []byte(`<ofd:Attachments ` + ns + `><ofd:Attachment Name="invoice.xml"><ofd:FileLoc>invoice.xml</ofd:FileLoc></ofd:Attachment></ofd:Attachments>`)},
+		{name: "Doc_0/Attachs/invoice.xml", bytes: []byte(xbrl)},
+	}
+}
+
 type zipEntry struct {
 	name  string
 	bytes []byte
@@ -255,6 +1305,27 @@ func writeNestedZipWithPDF(zipPath string, innerZipName string, pdfName string,
 	return outerW.Close()
 }
 
+type mailPart struct {
+	header string
+	body   string
+}
+
+func buildMultipartMail(parts []mailPart) []byte {
+	const boundary = "b0undary"
+	var b strings.Builder
+	b.WriteString("From: 12306 <12306@rails.com.cn>\r\n")
+	b.WriteString("Subject: invoice\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: multipart/mixed; boundary=\"" + boundary + "\"\r\n\r\n")
+	for _, p := range parts {
+		b.WriteString("--" + boundary + "\r\n")
+		b.WriteString(p.header + "\r\n\r\n")
+		b.WriteString(p.body + "\r\n")
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+	return []byte(b.String())
+}
+
 type logCollector struct {
 	lines []string
 }
@@ -266,3 +1337,19 @@ func newLogCollector() *logCollector {
 func (c *logCollector) Add(s string) {
 	c.lines = append(c.lines, s)
 }
+
+func (c *logCollector) Contains(parts ...string) bool {
+	for _, line := range c.lines {
+		matched := true
+		for _, p := range parts {
+			if !strings.Contains(line, p) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
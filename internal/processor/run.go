@@ -4,6 +4,7 @@ import (
 	"TrainTicketsTool/internal/invoice"
 	"archive/zip"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -13,72 +14,131 @@ import (
 	"strings"
 )
 
+var errLowConfidenceRejected = errors.New("未找到 XBRL，仅从 PDF 文本层识别（低可信度），已按配置拒绝")
+
 const (
 	pdfExt = ".pdf"
 	zipExt = ".zip"
+	ofdExt = invoice.OFDExt
 )
 
 func Run(cfg Config, logLine func(string)) (Summary, error) {
-	if logLine == nil {
-		return Summary{}, errors.New("logLine 不能为空")
+	onEvent, err := eventHandlerFromLogLine(logLine)
+	if err != nil {
+		return Summary{}, err
+	}
+	return RunWithEvents(context.Background(), cfg, onEvent)
+}
+
+func RunWithEvents(ctx context.Context, cfg Config, onEvent func(Event)) (Summary, error) {
+	r, err := newRunner(ctx, cfg, onEvent)
+	if err != nil {
+		return Summary{}, err
+	}
+	if err := EnsureDir(r.cfg.OutputDir); err != nil {
+		return Summary{}, err
+	}
+	logStaleTempFiles(r.cfg.OutputDir, r.emit)
+	if r.journal, err = newJournal(r.cfg); err != nil {
+		return Summary{}, err
+	}
+	walkErr := r.walk()
+	return *r.sum, finishJournal(r.journal, finishState(r.state, walkErr))
+}
+
+func newRunner(ctx context.Context, cfg Config, onEvent func(Event)) (*runner, error) {
+	if onEvent == nil {
+		return nil, errors.New("onEvent 不能为空")
+	}
+	if cfg.FileTimeout < 0 {
+		return nil, errors.New("单文件超时不能为负数")
 	}
 	if strings.TrimSpace(cfg.InputDir) == "" {
-		return Summary{}, errors.New("未选择输入目录")
+		return nil, errors.New("未选择输入目录")
 	}
 	if strings.TrimSpace(cfg.OutputDir) == "" {
-		return Summary{}, errors.New("未选择输出目录")
+		return nil, errors.New("未选择输出目录")
 	}
 
 	normalizedCfg, err := normalizeConfigDirs(cfg)
 	if err != nil {
-		return Summary{}, err
+		return nil, err
 	}
-	if sameDir(normalizedCfg.InputDir, normalizedCfg.OutputDir) {
-		return Summary{}, errors.New("输出目录不能与输入目录相同")
+	if !normalizedCfg.TransferMode.valid() {
+		return nil, fmt.Errorf("未知传输方式: %d", normalizedCfg.TransferMode)
 	}
-
-	skipOutputDir := ""
-	if isChildDir(normalizedCfg.OutputDir, normalizedCfg.InputDir) {
-		skipOutputDir = normalizedCfg.OutputDir
-		logLine(fmt.Sprintf("INFO: 输出目录位于输入目录下，扫描时将跳过输出目录: %s", skipOutputDir))
+	if normalizedCfg.TransferMode != TransferRenameInPlace && sameDir(normalizedCfg.InputDir, normalizedCfg.OutputDir) {
+		return nil, errors.New("输出目录不能与输入目录相同")
 	}
-
-	if err := EnsureDir(normalizedCfg.OutputDir); err != nil {
-		return Summary{}, err
+	if normalizedCfg.TransferMode == TransferRenameInPlace && strings.TrimSpace(normalizedCfg.DirTemplate) != "" {
+		return nil, errRenameWithDirTemplate
+	}
+	nameTmpl, err := parseNameTemplate(normalizedCfg.NameTemplate)
+	if err != nil {
+		return nil, err
+	}
+	dirTmpl, err := parseDirTemplate(normalizedCfg.DirTemplate)
+	if err != nil {
+		return nil, err
+	}
+	state, err := openStateStore(normalizedCfg)
+	if err != nil {
+		return nil, err
 	}
 
-	sum := Summary{}
-	r := runner{
-		cfg:          normalizedCfg,
-		logLine:      logLine,
-		sum:          &sum,
-		skipDir:      skipOutputDir,
-		seenPDFNames: make(map[string]struct{}),
-	}
-	if err := r.walk(); err != nil {
-		return sum, err
-	}
-	return sum, nil
+	r := &runner{
+		ctx:         ctx,
+		cfg:         normalizedCfg,
+		onEvent:     onEvent,
+		sum:         &Summary{},
+		nameTmpl:    nameTmpl,
+		dirTmpl:     dirTmpl,
+		seenNames:   make(map[string]SourceRef),
+		seenContent: make(map[string]SourceRef),
+		state:       state,
+	}
+	if isChildDir(normalizedCfg.OutputDir, normalizedCfg.InputDir) {
+		r.skipDir = normalizedCfg.OutputDir
+		r.emit(Event{Kind: EventInfo, Message: fmt.Sprintf("输出目录位于输入目录下，扫描时将跳过输出目录: %s", r.skipDir)})
+	}
+	r.logTransferMode()
+	return r, nil
 }
 
 type runner struct {
-	cfg          Config
-	logLine      func(string)
-	sum          *Summary
-	skipDir      string
-	seenPDFNames map[string]struct{}
+	ctx         context.Context
+	cfg         Config
+	onEvent     func(Event)
+	sum         *Summary
+	skipDir     string
+	nameTmpl    nameTemplate
+	dirTmpl     dirTemplate
+	seenNames   map[string]SourceRef
+	seenContent map[string]SourceRef
+	pipe        *pdfPipeline
+	plan        *Plan
+	reserved    map[string]struct{}
+	journal     *Journal
+	state       *stateStore
 }
 
-func (r *runner) walk() error {
-	return filepath.WalkDir(r.cfg.InputDir, func(path string, d fs.DirEntry, err error) error {
+func (r *runner) walkInput() error {
+	err := filepath.WalkDir(r.cfg.InputDir, func(path string, d fs.DirEntry, err error) error {
 		return r.onWalk(path, d, err)
 	})
+	if ctxErr := r.ctx.Err(); ctxErr != nil {
+		return canceledError(ctxErr)
+	}
+	return err
 }
 
 func (r *runner) onWalk(path string, d fs.DirEntry, walkErr error) error {
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	src := fileSource(path)
 	if walkErr != nil {
-		r.logLine(fmt.Sprintf("ERR: 访问失败: %s: %v", path, walkErr))
-		r.sum.Failed++
+		r.submitFailure(src, fmt.Errorf("访问失败: %w", walkErr))
 		return nil
 	}
 	if d.IsDir() {
@@ -87,74 +147,135 @@ func (r *runner) onWalk(path string, d fs.DirEntry, walkErr error) error {
 		}
 		return nil
 	}
+	if IsTempFile(path) {
+		return nil
+	}
 
-	switch strings.ToLower(filepath.Ext(path)) {
-	case pdfExt:
-		key := pdfDedupKeyFromFilePath(path)
-		if !r.shouldProcessPDFByKey(key) {
-			r.logSkipDuplicatePDF(path)
-			return nil
-		}
-		r.sum.FoundPDF++
-		if err := r.processPDFFile(path); err != nil {
-			r.logLine(fmt.Sprintf("ERR: %s: %v", path, err))
-			r.sum.Failed++
-			return nil
-		}
-		r.sum.Succeeded++
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case pdfExt, ofdExt:
+		r.submitPDF(src, r.fileStateKey(path, d), func() ([]byte, error) {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("读取 %s 失败: %w", documentLabel(ext), err)
+			}
+			return b, nil
+		}, false)
 	case zipExt:
-		if err := r.processZipFile(path); err != nil {
-			r.logLine(fmt.Sprintf("ERR: %s: %v", path, err))
-			r.sum.Failed++
-		}
+		r.processContainer(src, r.processZipFile)
+	case emlExt:
+		r.processContainer(src, r.processMailFile)
+	case mboxExt:
+		r.processContainer(src, r.processMboxFile)
 	default:
 		return nil
 	}
-	return nil
+	return r.ctx.Err()
 }
 
-func (r *runner) processPDFFile(path string) error {
-	pdfBytes, err := os.ReadFile(path)
+func (r *runner) fileStateKey(path string, d fs.DirEntry) string {
+	if r.state == nil {
+		return ""
+	}
+	info, err := d.Info()
 	if err != nil {
-		return fmt.Errorf("读取 PDF 失败: %w", err)
+		return ""
 	}
-	return r.processPDFBytes(path, pdfBytes)
+	return fileStateKey(path, info.Size(), info.ModTime())
 }
 
-func (r *runner) processPDFBytes(source string, pdfBytes []byte) error {
-	info, err := invoice.ExtractInvoiceInfoFromPDFBytes(pdfBytes)
-	if err != nil {
-		return err
+func (r *runner) processContainer(src SourceRef, process func(SourceRef) error) {
+	if err := process(src); err != nil && r.ctx.Err() == nil {
+		r.submitFailure(src, err)
 	}
-	dateStr, err := pickDate(info, r.cfg.DateField)
-	if err != nil {
-		return err
+}
+
+func (r *runner) fail(src SourceRef, err error) {
+	r.failWithInfo(src, nil, err)
+}
+
+func (r *runner) failWithInfo(src SourceRef, info *invoice.InvoiceInfo, err error) {
+	r.sum.Failed++
+	r.recordPlan(PlannedOp{Source: src, Action: PlanFail, Reason: err.Error()})
+	r.emit(Event{Kind: EventFailed, Source: src, Info: info, Err: err})
+}
+
+func (r *runner) finishPDF(job *pdfJob) {
+	src := job.src
+	if job.loadErr != nil {
+		r.failPDF(src, nil, job.loadErr)
+		return
+	}
+	if r.skipIfDuplicate(src, r.contentDedupKey(job.pdfBytes)) {
+		return
+	}
+	if job.extractErr != nil {
+		r.failPDF(src, nil, r.fileTimeoutError(job.extractErr))
+		return
+	}
+	info := job.info
+	if info.LowConfidence && r.cfg.RejectLowConfidence {
+		r.failPDF(src, &info, errLowConfidenceRejected)
+		return
+	}
+	if r.skipIfDuplicate(src, r.invoiceDedupKey(info, job.pdfBytes)) {
+		return
+	}
+
+	outPath, err := r.processPDFBytes(src, info, job.pdfBytes)
+	if errors.Is(err, errAlreadyNamed) {
+		r.logSkipInPlace(src, &info)
+		return
 	}
-	date, err := NormalizeDate(dateStr)
 	if err != nil {
-		return err
+		r.failPDF(src, &info, err)
+		return
+	}
+	r.sum.FoundPDF++
+	r.sum.Succeeded++
+	kind := EventWritten
+	if r.plan != nil {
+		kind = EventPlanned
+	} else {
+		r.state.record(job.stateKey, src, outPath, r.journal.RunID)
 	}
+	r.emit(Event{Kind: kind, Source: src, OutputPath: outPath, Info: &info})
+}
 
-	dep := SanitizeFileNamePart(info.DepartureStation)
-	dst := SanitizeFileNamePart(info.DestinationStation)
-	if dep == "" || dst == "" {
-		return errors.New("站点为空")
+func (r *runner) processPDFBytes(src SourceRef, info invoice.InvoiceInfo, pdfBytes []byte) (string, error) {
+	values := nameValues{info: info, dateField: r.cfg.DateField}
+	fileName, err := r.nameTmpl.render(values)
+	if err != nil {
+		return "", err
+	}
+	if ext := strings.ToLower(filepath.Ext(src.baseName())); ext == ofdExt {
+		fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ext
+	}
+	var outDir string
+	if r.renamesInPlace(src) {
+		if holdsOwnName(src.FilePath, fileName) {
+			return "", errAlreadyNamed
+		}
+		outDir = filepath.Dir(src.FilePath)
+	} else if outDir, err = r.dirTmpl.resolve(r.cfg.OutputDir, values); err != nil {
+		return "", err
+	}
+	if r.plan != nil {
+		return r.planWrite(src, outDir, fileName, pdfBytes)
 	}
 
-	fileName := fmt.Sprintf("%s-%s-%s.pdf", date, dep, dst)
-	if err := ValidateOutputFileName(fileName); err != nil {
-		return err
+	if err := EnsureDir(outDir); err != nil {
+		return "", fmt.Errorf("创建输出目录失败: %w", err)
 	}
-	outPath, err := WritePDF(r.cfg.OutputDir, fileName, pdfBytes)
+	outPath, moved, err := placeFile(r.cfg.TransferMode, src, outDir, nextFreePath(outDir, fileName), pdfBytes)
 	if err != nil {
-		return err
+		return "", err
 	}
-	r.logLine(fmt.Sprintf("OK: %s -> %s", source, outPath))
-	return nil
+	r.journal.record(src, outPath, pdfBytes, moved)
+	return outPath, nil
 }
 
-func (r *runner) processZipFile(path string) error {
-	f, err := os.Open(path)
+func (r *runner) processZipFile(src SourceRef) error {
+	f, err := os.Open(src.FilePath)
 	if err != nil {
 		return fmt.Errorf("打开 ZIP 失败: %w", err)
 	}
@@ -168,76 +289,76 @@ func (r *runner) processZipFile(path string) error {
 	if err != nil {
 		return fmt.Errorf("解析 ZIP 失败: %w", err)
 	}
-	return r.processZipReader(path, zr)
+	return r.processZipReader(src, zr)
 }
 
-func (r *runner) processZipReader(ctxPath string, zr *zip.Reader) error {
-	for _, entry := range zr.File {
+func (r *runner) processZipReader(parent SourceRef, zr *zip.Reader) error {
+	names := zipEntryNames(zr)
+	for i, entry := range zr.File {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
 		if entry.FileInfo().IsDir() {
 			continue
 		}
-		if err := r.processZipEntry(ctxPath, entry); err != nil {
-			r.sum.Failed++
-			r.logLine(fmt.Sprintf("ERR: %s!%s: %v", ctxPath, entry.Name, err))
+		src := parent.child(names[i])
+		if err := r.processZipEntry(src, entry); err != nil {
+			if ctxErr := r.ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			r.submitFailure(src, err)
 		}
 	}
 	return nil
 }
 
-func (r *runner) processZipEntry(ctxPath string, entry *zip.File) error {
-	switch strings.ToLower(filepath.Ext(entry.Name)) {
-	case pdfExt:
-		key := pdfDedupKeyFromZipEntryName(entry.Name)
-		if !r.shouldProcessPDFByKey(key) {
-			source := fmt.Sprintf("%s!%s", ctxPath, entry.Name)
-			r.logSkipDuplicatePDF(source)
-			return nil
-		}
-		r.sum.FoundPDF++
-		if err := r.processZipPDFEntry(ctxPath, entry); err != nil {
-			return err
-		}
-		r.sum.Succeeded++
+func (r *runner) processZipEntry(src SourceRef, entry *zip.File) error {
+	switch ext := strings.ToLower(filepath.Ext(entry.Name)); ext {
+	case pdfExt, ofdExt:
+		r.submitPDF(src, "", func() ([]byte, error) {
+			b, err := readZipEntry(entry)
+			if err != nil {
+				return nil, fmt.Errorf("读取 ZIP 内 %s 失败: %w", documentLabel(ext), err)
+			}
+			return b, nil
+		}, true)
 		return nil
 	case zipExt:
-		return r.processZipZipEntry(ctxPath, entry)
+		return r.processZipZipEntry(src, entry)
+	case emlExt:
+		b, err := readZipEntry(entry)
+		if err != nil {
+			return fmt.Errorf("读取 ZIP 内邮件失败: %w", err)
+		}
+		return r.processMailBytes(src, b)
 	default:
 		return nil
 	}
 }
 
-func (r *runner) processZipPDFEntry(ctxPath string, entry *zip.File) error {
-	rc, err := entry.Open()
+func (r *runner) processZipZipEntry(src SourceRef, entry *zip.File) error {
+	b, err := readZipEntry(entry)
 	if err != nil {
-		return fmt.Errorf("打开 ZIP 内 PDF 失败: %w", err)
+		return fmt.Errorf("读取 ZIP 内 ZIP 失败: %w", err)
 	}
-	defer rc.Close()
-
-	pdfBytes, err := io.ReadAll(rc)
+	nested, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
 	if err != nil {
-		return fmt.Errorf("读取 ZIP 内 PDF 失败: %w", err)
+		return fmt.Errorf("解析内层 ZIP 失败: %w", err)
 	}
-	source := fmt.Sprintf("%s!%s", ctxPath, entry.Name)
-	return r.processPDFBytes(source, pdfBytes)
+	return r.processZipReader(src, nested)
+}
+
+func documentLabel(ext string) string {
+	return strings.ToUpper(strings.TrimPrefix(ext, "."))
 }
 
-func (r *runner) processZipZipEntry(ctxPath string, entry *zip.File) error {
+func readZipEntry(entry *zip.File) ([]byte, error) {
 	rc, err := entry.Open()
 	if err != nil {
-		return fmt.Errorf("打开 ZIP 内 ZIP 失败: %w", err)
+		return nil, err
 	}
 	defer rc.Close()
-
-	b, err := io.ReadAll(rc)
-	if err != nil {
-		return fmt.Errorf("读取 ZIP 内 ZIP 失败: %w", err)
-	}
-	nested, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
-	if err != nil {
-		return fmt.Errorf("解析内层 ZIP 失败: %w", err)
-	}
-	nextCtx := fmt.Sprintf("%s!%s", ctxPath, entry.Name)
-	return r.processZipReader(nextCtx, nested)
+	return io.ReadAll(rc)
 }
 
 func pickDate(info invoice.InvoiceInfo, field invoice.DateField) (string, error) {
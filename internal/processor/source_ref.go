@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sourceEntrySep  = "!"
+	dupEntryNameFmt = "%s (%d)%s"
+)
+
+var (
+	sourceRefEscaper   = strings.NewReplacer("%", "%25", sourceEntrySep, "%21")
+	sourceRefUnescaper = strings.NewReplacer("%25", "%", "%21", sourceEntrySep)
+)
+
+type SourceRef struct {
+	FilePath string   `json:"filePath"`
+	Entries  []string `json:"entries,omitempty"`
+}
+
+func fileSource(path string) SourceRef {
+	return SourceRef{FilePath: path}
+}
+
+func ParseSourceRef(s string) SourceRef {
+	parts := strings.Split(s, sourceEntrySep)
+	for i, part := range parts {
+		parts[i] = sourceRefUnescaper.Replace(part)
+	}
+	if len(parts) == 1 {
+		return fileSource(parts[0])
+	}
+	return SourceRef{FilePath: parts[0], Entries: parts[1:]}
+}
+
+func (s SourceRef) child(entryName string) SourceRef {
+	entries := make([]string, 0, len(s.Entries)+1)
+	entries = append(entries, s.Entries...)
+	entries = append(entries, entryName)
+	return SourceRef{FilePath: s.FilePath, Entries: entries}
+}
+
+func (s SourceRef) baseName() string {
+	if n := len(s.Entries); n > 0 {
+		return path.Base(s.Entries[n-1])
+	}
+	return filepath.Base(s.FilePath)
+}
+
+func (s SourceRef) String() string {
+	parts := make([]string, 0, len(s.Entries)+1)
+	parts = append(parts, sourceRefEscaper.Replace(s.FilePath))
+	for _, name := range s.Entries {
+		parts = append(parts, sourceRefEscaper.Replace(name))
+	}
+	return strings.Join(parts, sourceEntrySep)
+}
+
+func uniqueEntryNames(names []string) []string {
+	taken := make(map[string]bool, len(names))
+	for _, name := range names {
+		taken[name] = true
+	}
+	next := make(map[string]int, len(names))
+	out := make([]string, len(names))
+	for i, name := range names {
+		n, seen := next[name]
+		if !seen {
+			next[name] = 1
+			out[i] = name
+			continue
+		}
+		ext := path.Ext(name)
+		for {
+			n++
+			candidate := fmt.Sprintf(dupEntryNameFmt, strings.TrimSuffix(name, ext), n, ext)
+			if !taken[candidate] {
+				taken[candidate] = true
+				next[name] = n
+				out[i] = candidate
+				break
+			}
+		}
+	}
+	return out
+}
+
+func zipEntryNames(zr *zip.Reader) []string {
+	names := make([]string, len(zr.File))
+	for i, f := range zr.File {
+		names[i] = f.Name
+	}
+	return uniqueEntryNames(names)
+}
+
+func ReadSourceBytes(s SourceRef) ([]byte, error) {
+	b, err := os.ReadFile(s.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	container := s.FilePath
+	for _, name := range s.Entries {
+		b, err = readContainerEntry(container, b, name)
+		if err != nil {
+			return nil, err
+		}
+		container = name
+	}
+	return b, nil
+}
+
+func readContainerEntry(container string, b []byte, name string) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(container)) {
+	case zipExt:
+		return readZipEntryByName(b, name)
+	case emlExt:
+		return readMailAttachmentByName(b, name)
+	case mboxExt:
+		return readMboxMessageByName(b, name)
+	default:
+		return nil, fmt.Errorf("不支持的容器类型: %s", container)
+	}
+}
+
+func readZipEntryByName(b []byte, name string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("解析 ZIP 失败: %w", err)
+	}
+	entry := findZipEntry(zr, name)
+	if entry == nil {
+		return nil, fmt.Errorf("ZIP 内未找到: %s", name)
+	}
+	out, err := readZipEntry(entry)
+	if err != nil {
+		return nil, fmt.Errorf("读取 ZIP 内文件失败: %w", err)
+	}
+	return out, nil
+}
+
+func findZipEntry(zr *zip.Reader, name string) *zip.File {
+	for i, entryName := range zipEntryNames(zr) {
+		if entryName == name {
+			return zr.File[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	StateFileName = ".invoice-state.json"
+	stateVersion  = 1
+
+	stateSaveInterval = time.Second
+
+	stateKeyFile  = "file"
+	stateKeyEntry = "entry"
+	stateKeySep   = "|"
+)
+
+var stateSaveBatch = 64
+
+type StateEntry struct {
+	Source      SourceRef `json:"source"`
+	Output      string    `json:"output"`
+	RunID       string    `json:"runId,omitempty"`
+	ProcessedAt time.Time `json:"processedAt"`
+}
+
+type State struct {
+	Version int                   `json:"version"`
+	Entries map[string]StateEntry `json:"entries"`
+}
+
+type stateStore struct {
+	outputDir string
+	state     State
+	added     map[string]StateEntry
+	dirty     bool
+	lastSave  time.Time
+	err       error
+}
+
+func statePath(outputDir string) string {
+	return filepath.Join(outputDir, StateFileName)
+}
+
+func LoadState(outputDir string) (State, error) {
+	st := State{Version: stateVersion, Entries: make(map[string]StateEntry)}
+	b, err := os.ReadFile(statePath(outputDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return st, nil
+		}
+		return State{}, fmt.Errorf("读取处理记录失败: %w", err)
+	}
+	if err := json.Unmarshal(b, &st); err != nil {
+		return State{}, fmt.Errorf("解析处理记录失败（%s）: %w", StateFileName, err)
+	}
+	if st.Version > stateVersion {
+		return State{}, fmt.Errorf("处理记录版本 %d 过新，无法识别", st.Version)
+	}
+	if st.Entries == nil {
+		st.Entries = make(map[string]StateEntry)
+	}
+	return st, nil
+}
+
+func openStateStore(cfg Config) (*stateStore, error) {
+	if !cfg.Incremental {
+		return nil, nil
+	}
+	st, err := LoadState(cfg.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	return &stateStore{outputDir: cfg.OutputDir, state: st, added: make(map[string]StateEntry)}, nil
+}
+
+func fileStateKey(path string, size int64, modTime time.Time) string {
+	return stateKeyFile + stateKeySep + path + stateKeySep + strconv.FormatInt(size, 10) + stateKeySep + strconv.FormatInt(modTime.UnixNano(), 10)
+}
+
+func entryStateKey(src SourceRef, pdfBytes []byte) string {
+	return stateKeyEntry + stateKeySep + src.String() + stateKeySep + sha256Hex(pdfBytes)
+}
+
+func sourceStateKey(src SourceRef, pdfBytes []byte) (string, error) {
+	if len(src.Entries) > 0 {
+		return entryStateKey(src, pdfBytes), nil
+	}
+	info, err := os.Stat(src.FilePath)
+	if err != nil {
+		return "", err
+	}
+	return fileStateKey(src.FilePath, info.Size(), info.ModTime()), nil
+}
+
+func (s *stateStore) lookup(key string) (StateEntry, bool) {
+	if s == nil || key == "" {
+		return StateEntry{}, false
+	}
+	entry, ok := s.state.Entries[key]
+	if !ok {
+		return StateEntry{}, false
+	}
+	if exists, err := fileExists(entry.Output); err != nil || !exists {
+		return StateEntry{}, false
+	}
+	return entry, true
+}
+
+func (s *stateStore) record(key string, src SourceRef, outPath string, runID string) {
+	if s == nil || key == "" {
+		return
+	}
+	s.added[key] = StateEntry{Source: src, Output: outPath, RunID: runID, ProcessedAt: time.Now()}
+	if s.err == nil && (len(s.added) >= stateSaveBatch || time.Since(s.lastSave) >= stateSaveInterval) {
+		s.err = s.save()
+	}
+}
+
+func (s *stateStore) save() error {
+	if s == nil || (len(s.added) == 0 && !s.dirty) {
+		return nil
+	}
+	for key, entry := range s.added {
+		s.state.Entries[key] = entry
+	}
+	s.added = make(map[string]StateEntry)
+	s.dirty = true
+	s.state.Version = stateVersion
+	b, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化处理记录失败: %w", err)
+	}
+	b = append(b, '\n')
+
+	if err := replaceFileAtomic(statePath(s.outputDir), b); err != nil {
+		return fmt.Errorf("写入处理记录失败: %w", err)
+	}
+	s.dirty = false
+	s.lastSave = time.Now()
+	return nil
+}
+
+func finishState(s *stateStore, runErr error) error {
+	if err := s.save(); err != nil {
+		if runErr != nil {
+			return fmt.Errorf("%w（%v）", runErr, err)
+		}
+		return err
+	}
+	return runErr
+}
+
+func (r *runner) logSkipProcessed(src SourceRef, entry StateEntry) {
+	reason := fmt.Sprintf("已处理过（输出: %s）", entry.Output)
+	r.recordPlan(PlannedOp{Source: src, Action: PlanSkip, Reason: reason})
+	r.emit(Event{Kind: EventSkipped, Source: src, Message: reason})
+}
@@ -1,8 +1,7 @@
 package processor
 
 type Summary struct {
-	FoundPDF  int
-	Succeeded int
-	Failed    int
+	FoundPDF  int `json:"foundPdf"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
 }
-
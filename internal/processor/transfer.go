@@ -0,0 +1,130 @@
+package processor
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type TransferMode int
+
+const (
+	TransferCopy TransferMode = iota
+	TransferMove
+	TransferHardLink
+	TransferRenameInPlace
+)
+
+var (
+	errTargetExists = errors.New("目标文件已存在")
+	errAlreadyNamed = errors.New("文件名已符合模板，无需重命名")
+
+	errRenameWithDirTemplate = errors.New("原地重命名不支持子目录模板：文件总是留在原目录")
+)
+
+func (m TransferMode) String() string {
+	switch m {
+	case TransferCopy:
+		return "copy"
+	case TransferMove:
+		return "move"
+	case TransferHardLink:
+		return "hardlink"
+	case TransferRenameInPlace:
+		return "rename"
+	default:
+		return "unknown"
+	}
+}
+
+func (m TransferMode) label() string {
+	switch m {
+	case TransferCopy:
+		return "复制"
+	case TransferMove:
+		return "移动"
+	case TransferHardLink:
+		return "硬链接"
+	case TransferRenameInPlace:
+		return "原地重命名"
+	default:
+		return "未知"
+	}
+}
+
+func (m TransferMode) valid() bool {
+	return m >= TransferCopy && m <= TransferRenameInPlace
+}
+
+func transfersSource(mode TransferMode, src SourceRef) bool {
+	return mode != TransferCopy && len(src.Entries) == 0
+}
+
+func (r *runner) renamesInPlace(src SourceRef) bool {
+	return r.cfg.TransferMode == TransferRenameInPlace && transfersSource(r.cfg.TransferMode, src)
+}
+
+func holdsOwnName(path string, fileName string) bool {
+	name := pathKey(filepath.Base(path))
+	want := pathKey(fileName)
+	if name == want {
+		return true
+	}
+	ext := filepath.Ext(want)
+	if !strings.HasSuffix(name, ext) {
+		return false
+	}
+	suffix, ok := strings.CutPrefix(strings.TrimSuffix(name, ext), strings.TrimSuffix(want, ext)+"-")
+	if !ok {
+		return false
+	}
+	n, err := strconv.Atoi(suffix)
+	return err == nil && n >= firstCollisionNum && strconv.Itoa(n) == suffix
+}
+
+func (r *runner) logSkipInPlace(src SourceRef, info *invoice.InvoiceInfo) {
+	r.recordPlan(PlannedOp{Source: src, Action: PlanSkip, Reason: errAlreadyNamed.Error()})
+	r.emit(Event{Kind: EventSkipped, Source: src, OutputPath: src.FilePath, Message: errAlreadyNamed.Error(), Info: info})
+}
+
+func (r *runner) logTransferMode() {
+	if r.cfg.TransferMode == TransferCopy {
+		return
+	}
+	r.emit(Event{Kind: EventInfo, Message: fmt.Sprintf("传输方式: %s（ZIP / 邮件中的 PDF 仍写入新文件）", r.cfg.TransferMode.label())})
+}
+
+func placeFile(mode TransferMode, src SourceRef, dir string, pick func() (string, error), pdfBytes []byte) (string, bool, error) {
+	if !transfersSource(mode, src) {
+		target, err := writeFileAtomic(dir, pick, pdfBytes)
+		return target, false, err
+	}
+	if mode == TransferHardLink {
+		target, err := commitNoReplace(pick, func(target string) error {
+			return os.Link(src.FilePath, target)
+		})
+		if isLinkError(err) {
+			target, err = writeFileAtomic(dir, pick, pdfBytes)
+		} else if err == nil {
+			syncDir(dir)
+		}
+		return target, false, err
+	}
+	target, err := moveNoReplace(src.FilePath, dir, pick, pdfBytes)
+	return target, err == nil, err
+}
+
+func restoreMovedFile(entry JournalEntry, data []byte) error {
+	dir := filepath.Dir(entry.Source.FilePath)
+	if err := EnsureDir(dir); err != nil {
+		return fmt.Errorf("创建原目录失败: %w", err)
+	}
+	if _, err := moveNoReplace(entry.Path, dir, fixedPath(entry.Source.FilePath), data); err != nil {
+		return fmt.Errorf("移回原位置失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultWatchInterval = 2 * time.Second
+
+var watchedExts = map[string]bool{
+	pdfExt:  true,
+	ofdExt:  true,
+	zipExt:  true,
+	emlExt:  true,
+	mboxExt: true,
+}
+
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+}
+
+func (f watchedFile) same(o watchedFile) bool {
+	return f.size == o.size && f.modTime.Equal(o.modTime)
+}
+
+type watcher struct {
+	seen    map[string]watchedFile
+	handled map[string]watchedFile
+	failed  map[string]string
+}
+
+func Watch(ctx context.Context, cfg Config, interval time.Duration, onEvent func(Event)) (Summary, error) {
+	if interval < 0 {
+		return Summary{}, errors.New("监视间隔不能为负数")
+	}
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+	r, err := newRunner(ctx, cfg, onEvent)
+	if err != nil {
+		return Summary{}, err
+	}
+	if err := EnsureDir(r.cfg.OutputDir); err != nil {
+		return Summary{}, err
+	}
+	logStaleTempFiles(r.cfg.OutputDir, r.emit)
+	if r.journal, err = newJournal(r.cfg); err != nil {
+		return Summary{}, err
+	}
+	r.emit(Event{Kind: EventInfo, Message: fmt.Sprintf("开始监视输入目录: %s（每 %s 检查一次）", r.cfg.InputDir, interval)})
+
+	w := &watcher{seen: make(map[string]watchedFile), handled: make(map[string]watchedFile), failed: make(map[string]string)}
+	for {
+		if ready := w.poll(r); len(ready) > 0 {
+			r.emit(Event{Kind: EventInfo, Message: fmt.Sprintf("发现 %d 个新的或已变化的文件", len(ready))})
+			if err := r.walkWith(func() error { return r.processPaths(ready) }); err != nil {
+				return *r.sum, finishJournal(r.journal, finishState(r.state, err))
+			}
+			if err := finishJournal(r.journal, finishState(r.state, nil)); err != nil {
+				return *r.sum, err
+			}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return *r.sum, finishJournal(r.journal, finishState(r.state, canceledError(ctx.Err())))
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *watcher) poll(r *runner) []string {
+	current := make(map[string]watchedFile)
+	walkErrs := make(map[string]error)
+	_ = filepath.WalkDir(r.cfg.InputDir, func(path string, d fs.DirEntry, err error) error {
+		if r.ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			walkErrs[path] = err
+			return nil
+		}
+		if d.IsDir() {
+			if r.skipDir != "" && sameDir(path, r.skipDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if IsTempFile(path) || !watchedExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			current[path] = watchedFile{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	})
+
+	w.reportWalkErrors(r, walkErrs)
+
+	var ready []string
+	for path, obs := range current {
+		if h, ok := w.handled[path]; ok && h.same(obs) {
+			continue
+		}
+		if prev, ok := w.seen[path]; ok && prev.same(obs) {
+			ready = append(ready, path)
+		}
+	}
+	for path := range w.handled {
+		if _, ok := current[path]; !ok {
+			delete(w.handled, path)
+		}
+	}
+	for _, path := range ready {
+		w.handled[path] = current[path]
+	}
+	w.seen = current
+	sort.Strings(ready)
+	return ready
+}
+
+func (w *watcher) reportWalkErrors(r *runner, walkErrs map[string]error) {
+	paths := make([]string, 0, len(walkErrs))
+	for path := range walkErrs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	failed := make(map[string]string, len(paths))
+	for _, path := range paths {
+		msg := walkErrs[path].Error()
+		failed[path] = msg
+		if w.failed[path] != msg {
+			r.fail(fileSource(path), fmt.Errorf("访问失败: %w", walkErrs[path]))
+		}
+	}
+	w.failed = failed
+}
+
+func (r *runner) processPaths(paths []string) error {
+	for _, path := range paths {
+		if err := r.ctx.Err(); err != nil {
+			return canceledError(err)
+		}
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := r.onWalk(path, fs.FileInfoToDirEntry(info), err); err != nil {
+			if ctxErr := r.ctx.Err(); ctxErr != nil {
+				return canceledError(ctxErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
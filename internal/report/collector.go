@@ -0,0 +1,27 @@
+package report
+
+import "TrainTicketsTool/internal/processor"
+
+type Collector struct {
+	rows []Row
+}
+
+func (c *Collector) Handler(next func(processor.Event)) func(processor.Event) {
+	return func(e processor.Event) {
+		c.Add(e)
+		if next != nil {
+			next(e)
+		}
+	}
+}
+
+func (c *Collector) Add(e processor.Event) {
+	if e.Kind != processor.EventWritten || e.Info == nil {
+		return
+	}
+	c.rows = append(c.rows, Row{Info: *e.Info, OutputPath: e.OutputPath, Source: e.Source.String()})
+}
+
+func (c *Collector) Rows() []Row {
+	return c.rows
+}
@@ -0,0 +1,40 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+func WriteCSV(w io.Writer, rep Report) error {
+	if _, err := w.Write(utf8BOM); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	for i, t := range rep.tables() {
+		if i > 0 {
+			if err := cw.Write(nil); err != nil {
+				return err
+			}
+			if err := cw.Write([]string{t.name}); err != nil {
+				return err
+			}
+		}
+		if err := cw.Write(t.header); err != nil {
+			return err
+		}
+		for _, row := range t.rows {
+			record := make([]string, len(row))
+			for j, c := range row {
+				record[j] = c.text
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
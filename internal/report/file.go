@@ -0,0 +1,51 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	csvExt  = ".csv"
+	xlsxExt = ".xlsx"
+
+	reportFileMode = 0o644
+)
+
+func ValidatePath(path string) error {
+	_, err := writerFor(path)
+	return err
+}
+
+func WriteFile(path string, rep Report) error {
+	write, err := writerFor(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, reportFileMode)
+	if err != nil {
+		return fmt.Errorf("创建报表文件失败: %w", err)
+	}
+	if err := write(f, rep); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("写入报表失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("写入报表失败: %w", err)
+	}
+	return nil
+}
+
+func writerFor(path string) (func(io.Writer, Report) error, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case csvExt:
+		return WriteCSV, nil
+	case xlsxExt:
+		return WriteXLSX, nil
+	default:
+		return nil, fmt.Errorf("不支持的报表格式（仅支持 %s / %s）: %s", csvExt, xlsxExt, path)
+	}
+}
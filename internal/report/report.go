@@ -0,0 +1,105 @@
+package report
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"TrainTicketsTool/internal/processor"
+	"TrainTicketsTool/internal/vat"
+	"sort"
+	"strings"
+)
+
+const unknownKey = "（未知）"
+
+type Row struct {
+	Info       invoice.InvoiceInfo
+	OutputPath string
+	Source     string
+}
+
+type Total struct {
+	Key         string
+	Count       int
+	AmountCents int64
+}
+
+type Options struct {
+	DateField invoice.DateField
+	VATPeriod vat.Period
+}
+
+type Report struct {
+	DateField   invoice.DateField
+	Rows        []Row
+	ByMonth     []Total
+	ByPassenger []Total
+	Total       Total
+	Tickets     []vat.Ticket
+	VAT         vat.Summary
+}
+
+func Build(rows []Row, opts Options) Report {
+	field := opts.DateField
+	rep := Report{DateField: field, Rows: rows, Total: Total{Key: "合计"}}
+	months := map[string]*Total{}
+	passengers := map[string]*Total{}
+	for _, row := range rows {
+		cents, _ := invoice.ParseAmountCents(row.Info.Fare)
+		addTotal(months, rowMonth(row, field), cents)
+		addTotal(passengers, keyOrUnknown(row.Info.PassengerName), cents)
+		rep.Total.Count++
+		rep.Total.AmountCents += cents
+		rep.Tickets = append(rep.Tickets, vat.Compute(row.Info))
+	}
+	rep.ByMonth = sortedTotals(months)
+	rep.ByPassenger = sortedTotals(passengers)
+	rep.VAT = vat.Aggregate(rep.Tickets, opts.VATPeriod)
+	return rep
+}
+
+func rowDate(row Row, field invoice.DateField) string {
+	raw := row.Info.TravelDate
+	if field == invoice.DateFieldIssue {
+		raw = row.Info.DateOfIssue
+	}
+	date, err := processor.NormalizeDate(raw)
+	if err != nil {
+		return strings.TrimSpace(raw)
+	}
+	return date
+}
+
+func rowMonth(row Row, field invoice.DateField) string {
+	date := rowDate(row, field)
+	if len(date) < len("2006-01") {
+		return unknownKey
+	}
+	return date[:len("2006-01")]
+}
+
+func keyOrUnknown(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return unknownKey
+	}
+	return strings.TrimSpace(s)
+}
+
+func addTotal(m map[string]*Total, key string, cents int64) {
+	t, ok := m[key]
+	if !ok {
+		t = &Total{Key: key}
+		m[key] = t
+	}
+	t.Count++
+	t.AmountCents += cents
+}
+
+func sortedTotals(m map[string]*Total) []Total {
+	out := make([]Total, 0, len(m))
+	for _, t := range m {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		return out[a].Key < out[b].Key
+	})
+	return out
+}
@@ -0,0 +1,120 @@
+package report
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"TrainTicketsTool/internal/processor"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuild_TotalsByMonthAndPassenger(t *testing.T) {
+	rows := []Row{
+		{Info: invoice.InvoiceInfo{TravelDate: "2026-02-24", PassengerName: "张三", Fare: "¥123.50"}},
+		{Info: invoice.InvoiceInfo{TravelDate: "20260228", PassengerName: "李四", Fare: "100"}},
+		{Info: invoice.InvoiceInfo{TravelDate: "2026-03-01", PassengerName: "张三", Fare: "1,000.5"}},
+	}
+	rep := Build(rows, Options{DateField: invoice.DateFieldTravel})
+
+	if rep.Total.Count != 3 || rep.Total.AmountCents != 122400 {
+		t.Fatalf("unexpected grand total: %+v", rep.Total)
+	}
+	wantMonths := []Total{{Key: "2026-02", Count: 2, AmountCents: 22350}, {Key: "2026-03", Count: 1, AmountCents: 100050}}
+	if len(rep.ByMonth) != 2 || rep.ByMonth[0] != wantMonths[0] || rep.ByMonth[1] != wantMonths[1] {
+		t.Fatalf("unexpected month totals: %+v", rep.ByMonth)
+	}
+	for _, p := range rep.ByPassenger {
+		if p.Key == "张三" && (p.Count != 2 || p.AmountCents != 112400) {
+			t.Fatalf("unexpected passenger total: %+v", p)
+		}
+	}
+}
+
+func TestWriteCSVAndXLSX(t *testing.T) {
+	rep := Build([]Row{{
+		Info:       invoice.InvoiceInfo{TravelDate: "2026-02-24", DepartureStation: "郑州东", DestinationStation: "三门峡南", PassengerName: "张三", Fare: "123.50"},
+		OutputPath: filepath.Join("out", "2026-02-24-郑州东-三门峡南.pdf"),
+		Source:     "in/a.zip!x.pdf",
+	}}, Options{DateField: invoice.DateFieldTravel})
+
+	var csvBuf bytes.Buffer
+	if err := WriteCSV(&csvBuf, rep); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	if !bytes.HasPrefix(csvBuf.Bytes(), utf8BOM) {
+		t.Fatalf("csv missing BOM")
+	}
+	r := csv.NewReader(bytes.NewReader(csvBuf.Bytes()[len(utf8BOM):]))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if records[0][0] != "日期" || records[1][2] != "郑州东" || records[1][7] != "123.50" || records[1][15] != "2026-02-24-郑州东-三门峡南.pdf" || records[1][16] != "in/a.zip!x.pdf" {
+		t.Fatalf("unexpected csv detail rows: %q", records[:2])
+	}
+
+	var xlsxBuf bytes.Buffer
+	if err := WriteXLSX(&xlsxBuf, rep); err != nil {
+		t.Fatalf("WriteXLSX: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(xlsxBuf.Bytes()), int64(xlsxBuf.Len()))
+	if err != nil {
+		t.Fatalf("xlsx is not a zip: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "xl/workbook.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet3.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("xlsx missing part %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/worksheets/sheet1.xml"], "<v>123.50</v>") || !strings.Contains(parts["xl/workbook.xml"], `name="按月合计"`) {
+		t.Fatalf("unexpected xlsx content: %s", parts["xl/worksheets/sheet1.xml"])
+	}
+}
+
+func TestScanOutputDir_UsesJournalSources(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := t.TempDir()
+	xbrl := `<xbrl xmlns:rai="urn:rai"><rai:TravelDate>2026-02-24</rai:TravelDate><rai:DepartureStation>郑州东</rai:DepartureStation><rai:DestinationStation>三门峡南</rai:DestinationStation></xbrl>`
+	pdfPath := filepath.Join(inDir, "a.pdf")
+	if err := os.WriteFile(pdfPath, []byte("%PDF-1.7\nstream\n"+xbrl+"\nendstream\n%%EOF\n"), 0o644); err != nil {
+		t.Fatalf("write pdf: %v", err)
+	}
+
+	var collector Collector
+	_, err := processor.RunWithEvents(context.Background(), processor.Config{
+		InputDir:  inDir,
+		OutputDir: outDir,
+		DateField: invoice.DateFieldTravel,
+	}, collector.Handler(nil))
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if len(collector.Rows()) != 1 || collector.Rows()[0].Source != pdfPath {
+		t.Fatalf("unexpected collected rows: %+v", collector.Rows())
+	}
+
+	rows, sum, err := ScanOutputDir(context.Background(), outDir, nil, func(processor.Event) {})
+	if err != nil {
+		t.Fatalf("ScanOutputDir error: %v", err)
+	}
+	if sum.Succeeded != 1 || len(rows) != 1 || rows[0].Source != pdfPath || rows[0].Info.DepartureStation != "郑州东" {
+		t.Fatalf("unexpected scan rows: %+v sum=%+v", rows, sum)
+	}
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"TrainTicketsTool/internal/processor"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const pdfExt = ".pdf"
+
+func isInvoiceFile(path string) bool {
+	ext := filepath.Ext(path)
+	return strings.EqualFold(ext, pdfExt) || strings.EqualFold(ext, invoice.OFDExt)
+}
+
+func ScanOutputDir(ctx context.Context, outputDir string, passwords []string, onEvent func(processor.Event)) ([]Row, processor.Summary, error) {
+	var sum processor.Summary
+	if onEvent == nil {
+		return nil, sum, errors.New("onEvent 不能为空")
+	}
+	if strings.TrimSpace(outputDir) == "" {
+		return nil, sum, errors.New("未选择输出目录")
+	}
+	dir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return nil, sum, fmt.Errorf("输出目录无效: %w", err)
+	}
+	sources, err := journalSources(dir)
+	if err != nil {
+		return nil, sum, err
+	}
+
+	fail := func(path string, err error) {
+		sum.Failed++
+		onEvent(processor.Event{Kind: processor.EventFailed, Source: processor.SourceRef{FilePath: path}, Err: err, Summary: sum})
+	}
+	var rows []Row
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if walkErr != nil {
+			fail(path, fmt.Errorf("访问失败: %w", walkErr))
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == processor.JournalDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if processor.IsTempFile(path) || !isInvoiceFile(path) {
+			return nil
+		}
+		sum.FoundPDF++
+		b, err := os.ReadFile(path)
+		if err != nil {
+			fail(path, fmt.Errorf("读取发票文件失败: %w", err))
+			return nil
+		}
+		info, err := invoice.ExtractInvoiceInfo(ctx, path, b, passwords)
+		if err != nil {
+			fail(path, err)
+			return nil
+		}
+		sum.Succeeded++
+		rows = append(rows, Row{Info: info, OutputPath: path, Source: sources[strings.ToLower(path)]})
+		return nil
+	})
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return rows, sum, fmt.Errorf("%w: %w", processor.ErrCanceled, ctxErr)
+	}
+	return rows, sum, err
+}
+
+func journalSources(outputDir string) (map[string]string, error) {
+	journals, err := processor.ListJournals(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	sources := make(map[string]string)
+	for _, j := range journals {
+		if j.UndoneAt != nil {
+			continue
+		}
+		for _, e := range j.Entries {
+			sources[strings.ToLower(e.Path)] = e.Source.String()
+		}
+	}
+	return sources, nil
+}
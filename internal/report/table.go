@@ -0,0 +1,122 @@
+package report
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"TrainTicketsTool/internal/vat"
+	"path/filepath"
+	"strconv"
+)
+
+type cell struct {
+	text    string
+	numeric bool
+}
+
+type table struct {
+	name   string
+	header []string
+	rows   [][]cell
+}
+
+func textCell(s string) cell {
+	return cell{text: s}
+}
+
+func amountCell(s string) cell {
+	cents, ok := invoice.ParseAmountCents(s)
+	if !ok {
+		return textCell(s)
+	}
+	return centsCell(cents)
+}
+
+func centsCell(cents int64) cell {
+	return cell{text: invoice.FormatCents(cents), numeric: true}
+}
+
+func countCell(n int) cell {
+	return cell{text: strconv.Itoa(n), numeric: true}
+}
+
+func (rep Report) tables() []table {
+	return []table{
+		rep.detailTable(),
+		totalsTable("按月合计", "月份", rep.ByMonth, rep.Total),
+		totalsTable("按乘车人合计", "乘车人", rep.ByPassenger, rep.Total),
+		vatTable(rep.VAT),
+	}
+}
+
+func (rep Report) detailTable() table {
+	t := table{
+		name:   "明细",
+		header: []string{"日期", "车次", "出发站", "到达站", "开车时间", "席别", "乘车人", "票价", "税额", "发票号码", "电子客票号", "购买方", "购买方税号", "抵扣依据", "可抵扣进项税", "输出文件", "来源"},
+	}
+	for n, row := range rep.Rows {
+		i := row.Info
+		ticket := rep.Tickets[n]
+		t.rows = append(t.rows, []cell{
+			textCell(rowDate(row, rep.DateField)),
+			textCell(i.TrainNumber),
+			textCell(i.DepartureStation),
+			textCell(i.DestinationStation),
+			textCell(i.DepartureTime),
+			textCell(i.SeatClass),
+			textCell(i.PassengerName),
+			amountCell(i.Fare),
+			amountCell(i.TaxAmount),
+			textCell(i.InvoiceNumber),
+			textCell(i.ElectronicTicketNumber),
+			textCell(i.BuyerName),
+			textCell(i.BuyerTaxID),
+			textCell(ticketBasis(ticket)),
+			vatCell(ticket),
+			textCell(filepath.Base(row.OutputPath)),
+			textCell(row.Source),
+		})
+	}
+	return t
+}
+
+func totalsTable(name string, keyHeader string, totals []Total, grand Total) table {
+	t := table{name: name, header: []string{keyHeader, "张数", "票价合计"}}
+	for _, tot := range append(append([]Total(nil), totals...), grand) {
+		t.rows = append(t.rows, []cell{textCell(tot.Key), countCell(tot.Count), centsCell(tot.AmountCents)})
+	}
+	return t
+}
+
+func ticketBasis(t vat.Ticket) string {
+	if t.Basis == vat.BasisExcluded {
+		return t.Basis.Label() + "：" + t.ExcludeReason
+	}
+	return t.Basis.Label()
+}
+
+func vatCell(t vat.Ticket) cell {
+	if t.Basis == vat.BasisExcluded {
+		return textCell("")
+	}
+	return centsCell(t.DeductibleCents)
+}
+
+func vatTable(sum vat.Summary) table {
+	periodHeader := "月份"
+	if sum.Period == vat.PeriodQuarter {
+		periodHeader = "季度"
+	}
+	t := table{name: "进项税抵扣", header: []string{"购买方税号", periodHeader, "可抵扣张数", "不可抵扣张数", "票价合计", "可抵扣进项税"}}
+	total := sum.Total
+	total.BuyerTaxID = "合计"
+	for _, g := range append(append([]vat.Group(nil), sum.ByBuyerPeriod...), total) {
+		t.rows = append(t.rows, []cell{
+			textCell(g.BuyerTaxID),
+			textCell(g.Period),
+			countCell(g.Tickets),
+			countCell(g.Excluded),
+			centsCell(g.FareCents),
+			centsCell(g.DeductibleCents),
+		})
+	}
+	return t
+}
@@ -0,0 +1,152 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	xlsxHeaderStyle = 1
+	xlsxAmountStyle = 2
+
+	xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+	nsMain    = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	nsRel     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsPkgRel  = "http://schemas.openxmlformats.org/package/2006/relationships"
+)
+
+const xlsxStyles = xmlHeader + `<styleSheet xmlns="` + nsMain + `">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs></styleSheet>`
+
+type xlsxPart struct {
+	name string
+	body []byte
+}
+
+func WriteXLSX(w io.Writer, rep Report) error {
+	tables := rep.tables()
+	zw := zip.NewWriter(w)
+	parts := []xlsxPart{
+		{"[Content_Types].xml", xlsxContentTypes(len(tables))},
+		{"_rels/.rels", []byte(xmlHeader + `<Relationships xmlns="` + nsPkgRel + `">` +
+			`<Relationship Id="rId1" Type="` + nsRel + `/officeDocument" Target="xl/workbook.xml"/></Relationships>`)},
+		{"xl/workbook.xml", xlsxWorkbook(tables)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(tables))},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+	}
+	for i, t := range tables {
+		parts = append(parts, xlsxPart{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), xlsxSheet(t)})
+	}
+
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(p.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func xlsxContentTypes(sheets int) []byte {
+	var b bytes.Buffer
+	b.WriteString(xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.Bytes()
+}
+
+func xlsxWorkbook(tables []table) []byte {
+	var b bytes.Buffer
+	b.WriteString(xmlHeader + `<workbook xmlns="` + nsMain + `" xmlns:r="` + nsRel + `"><sheets>`)
+	for i, t := range tables {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(t.name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.Bytes()
+}
+
+func xlsxWorkbookRels(sheets int) []byte {
+	var b bytes.Buffer
+	b.WriteString(xmlHeader + `<Relationships xmlns="` + nsPkgRel + `">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="%s/worksheet" Target="worksheets/sheet%d.xml"/>`, i, nsRel, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="%s/styles" Target="styles.xml"/>`, sheets+1, nsRel)
+	b.WriteString(`</Relationships>`)
+	return b.Bytes()
+}
+
+func xlsxSheet(t table) []byte {
+	var b bytes.Buffer
+	b.WriteString(xmlHeader + `<worksheet xmlns="` + nsMain + `"><sheetData>`)
+	header := make([]cell, len(t.header))
+	for i, h := range t.header {
+		header[i] = textCell(h)
+	}
+	writeXLSXRow(&b, 1, header, xlsxHeaderStyle)
+	for i, row := range t.rows {
+		writeXLSXRow(&b, i+2, row, 0)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes()
+}
+
+func writeXLSXRow(b *bytes.Buffer, rowNum int, cells []cell, style int) {
+	fmt.Fprintf(b, `<row r="%d">`, rowNum)
+	for i, c := range cells {
+		ref := columnName(i) + strconv.Itoa(rowNum)
+		switch {
+		case c.numeric:
+			s := style
+			if s == 0 && !isInteger(c.text) {
+				s = xlsxAmountStyle
+			}
+			fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, s, c.text)
+		case c.text == "":
+			continue
+		default:
+			fmt.Fprintf(b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(c.text))
+		}
+	}
+	b.WriteString(`</row>`)
+}
+
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func isInteger(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
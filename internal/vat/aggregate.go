@@ -0,0 +1,83 @@
+package vat
+
+import (
+	"sort"
+	"strings"
+)
+
+type Group struct {
+	BuyerTaxID      string
+	Period          string
+	Tickets         int
+	Excluded        int
+	FareCents       int64
+	DeductibleCents int64
+}
+
+type Summary struct {
+	Period        Period
+	ByBuyer       []Group
+	ByPeriod      []Group
+	ByBuyerPeriod []Group
+	Total         Group
+}
+
+type groupKey struct {
+	buyer  string
+	period string
+}
+
+func Aggregate(tickets []Ticket, period Period) Summary {
+	sum := Summary{Period: period}
+	byBuyer := map[groupKey]*Group{}
+	byPeriod := map[groupKey]*Group{}
+	byBoth := map[groupKey]*Group{}
+	for _, t := range tickets {
+		buyer := strings.TrimSpace(t.Info.BuyerTaxID)
+		if buyer == "" {
+			buyer = unknownKey
+		}
+		p := period.key(t.Info)
+		addToGroup(byBuyer, groupKey{buyer: buyer}, t)
+		addToGroup(byPeriod, groupKey{period: p}, t)
+		addToGroup(byBoth, groupKey{buyer: buyer, period: p}, t)
+		sum.Total.add(t)
+	}
+	sum.ByBuyer = sortedGroups(byBuyer)
+	sum.ByPeriod = sortedGroups(byPeriod)
+	sum.ByBuyerPeriod = sortedGroups(byBoth)
+	return sum
+}
+
+func (g *Group) add(t Ticket) {
+	if t.Basis == BasisExcluded {
+		g.Excluded++
+		return
+	}
+	g.Tickets++
+	g.FareCents += t.FareCents
+	g.DeductibleCents += t.DeductibleCents
+}
+
+func addToGroup(m map[groupKey]*Group, k groupKey, t Ticket) {
+	g, ok := m[k]
+	if !ok {
+		g = &Group{BuyerTaxID: k.buyer, Period: k.period}
+		m[k] = g
+	}
+	g.add(t)
+}
+
+func sortedGroups(m map[groupKey]*Group) []Group {
+	out := make([]Group, 0, len(m))
+	for _, g := range m {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].BuyerTaxID != out[b].BuyerTaxID {
+			return out[a].BuyerTaxID < out[b].BuyerTaxID
+		}
+		return out[a].Period < out[b].Period
+	})
+	return out
+}
@@ -0,0 +1,59 @@
+package vat
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Period int
+
+const (
+	PeriodMonth Period = iota
+	PeriodQuarter
+)
+
+const (
+	monthsPerQuarter = 3
+	unknownKey       = "（未知）"
+)
+
+var periodDateLayouts = []string{"2006-01-02", "2006/01/02", "20060102", "2006年01月02日", "2006年1月2日"}
+
+func (p Period) String() string {
+	switch p {
+	case PeriodMonth:
+		return "month"
+	case PeriodQuarter:
+		return "quarter"
+	default:
+		return "unknown"
+	}
+}
+
+func (p Period) key(info invoice.InvoiceInfo) string {
+	t, ok := accountingDate(info)
+	if !ok {
+		return unknownKey
+	}
+	if p == PeriodQuarter {
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/monthsPerQuarter+1)
+	}
+	return t.Format("2006-01")
+}
+
+func accountingDate(info invoice.InvoiceInfo) (time.Time, bool) {
+	for _, raw := range []string{info.DateOfIssue, info.TravelDate} {
+		s := strings.TrimSpace(raw)
+		if s == "" {
+			continue
+		}
+		for _, layout := range periodDateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
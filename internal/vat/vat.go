@@ -0,0 +1,106 @@
+package vat
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"strings"
+)
+
+const (
+	railwayRatePercent = 9
+	percentBase        = 100
+)
+
+type Basis int
+
+const (
+	BasisFormula Basis = iota
+	BasisExplicit
+	BasisExcluded
+)
+
+func (b Basis) String() string {
+	switch b {
+	case BasisFormula:
+		return "formula"
+	case BasisExplicit:
+		return "explicit"
+	case BasisExcluded:
+		return "excluded"
+	default:
+		return "unknown"
+	}
+}
+
+func (b Basis) Label() string {
+	switch b {
+	case BasisFormula:
+		return "按票价计算"
+	case BasisExplicit:
+		return "发票注明税额"
+	case BasisExcluded:
+		return "不可抵扣"
+	default:
+		return "未知"
+	}
+}
+
+type Ticket struct {
+	Info            invoice.InvoiceInfo
+	Basis           Basis
+	FareCents       int64
+	DeductibleCents int64
+	ExcludeReason   string
+}
+
+var nonTicketItemKeywords = []string{"退票", "改签", "手续费", "违约金", "服务费"}
+
+func Compute(info invoice.InvoiceInfo) Ticket {
+	t := Ticket{Info: info}
+	if reason := exclusionReason(info); reason != "" {
+		t.Basis = BasisExcluded
+		t.ExcludeReason = reason
+		return t
+	}
+
+	fare, ok := invoice.ParseAmountCents(info.Fare)
+	if !ok {
+		t.Basis = BasisExcluded
+		t.ExcludeReason = "票价无法识别"
+		return t
+	}
+	if fare <= 0 {
+		t.Basis = BasisExcluded
+		t.ExcludeReason = "票价为零或负数（红字发票）"
+		return t
+	}
+	t.FareCents = fare
+
+	if tax, ok := invoice.ParseAmountCents(info.TaxAmount); ok && tax > 0 {
+		t.Basis = BasisExplicit
+		t.DeductibleCents = tax
+		return t
+	}
+	t.Basis = BasisFormula
+	t.DeductibleCents = formulaTaxCents(fare)
+	return t
+}
+
+func formulaTaxCents(fareCents int64) int64 {
+	num := fareCents * railwayRatePercent
+	den := int64(percentBase + railwayRatePercent)
+	return (2*num + den) / (2 * den)
+}
+
+func exclusionReason(info invoice.InvoiceInfo) string {
+	if strings.TrimSpace(info.OriginalInvoiceNumber) != "" {
+		return "红字发票（对应原发票 " + strings.TrimSpace(info.OriginalInvoiceNumber) + "）"
+	}
+	for _, text := range []string{info.ItemName, info.Remarks} {
+		for _, kw := range nonTicketItemKeywords {
+			if strings.Contains(text, kw) {
+				return "退票费/改签费等非客票发票（" + kw + "）"
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package vat
+
+import (
+	"TrainTicketsTool/internal/invoice"
+	"testing"
+)
+
+func TestCompute_ExplicitFormulaAndExcluded(t *testing.T) {
+	cases := []struct {
+		name  string
+		info  invoice.InvoiceInfo
+		basis Basis
+		cents int64
+	}{
+		{"formula", invoice.InvoiceInfo{Fare: "¥109.00"}, BasisFormula, 900},
+		{"formula rounds half up", invoice.InvoiceInfo{Fare: "54.50"}, BasisFormula, 450},
+		{"explicit", invoice.InvoiceInfo{Fare: "223.00", TaxAmount: "18.41"}, BasisExplicit, 1841},
+		{"refund fee", invoice.InvoiceInfo{Fare: "10.00", ItemName: "退票费"}, BasisExcluded, 0},
+		{"red invoice", invoice.InvoiceInfo{Fare: "-109.00", OriginalInvoiceNumber: "26419000000123456789"}, BasisExcluded, 0},
+		{"negative fare", invoice.InvoiceInfo{Fare: "-109.00"}, BasisExcluded, 0},
+		{"missing fare", invoice.InvoiceInfo{}, BasisExcluded, 0},
+	}
+	for _, tc := range cases {
+		got := Compute(tc.info)
+		if got.Basis != tc.basis || got.DeductibleCents != tc.cents {
+			t.Fatalf("%s: got basis=%s cents=%d, want %s %d", tc.name, got.Basis, got.DeductibleCents, tc.basis, tc.cents)
+		}
+		if tc.basis == BasisExcluded && got.ExcludeReason == "" {
+			t.Fatalf("%s: missing exclude reason", tc.name)
+		}
+	}
+}
+
+func TestAggregate_ByBuyerAndQuarter(t *testing.T) {
+	tickets := []Ticket{
+		Compute(invoice.InvoiceInfo{DateOfIssue: "2026-02-28", BuyerTaxID: "91A", Fare: "109"}),
+		Compute(invoice.InvoiceInfo{DateOfIssue: "2026-03-05", BuyerTaxID: "91A", Fare: "218", TaxAmount: "18.00"}),
+		Compute(invoice.InvoiceInfo{TravelDate: "2026-04-01", BuyerTaxID: "91B", Fare: "109"}),
+		Compute(invoice.InvoiceInfo{DateOfIssue: "2026-03-06", BuyerTaxID: "91A", Fare: "5", ItemName: "改签费"}),
+	}
+	sum := Aggregate(tickets, PeriodQuarter)
+
+	if sum.Total.Tickets != 3 || sum.Total.Excluded != 1 || sum.Total.DeductibleCents != 900+1800+900 {
+		t.Fatalf("unexpected total: %+v", sum.Total)
+	}
+	want := []Group{
+		{BuyerTaxID: "91A", Period: "2026-Q1", Tickets: 2, Excluded: 1, FareCents: 32700, DeductibleCents: 2700},
+		{BuyerTaxID: "91B", Period: "2026-Q2", Tickets: 1, FareCents: 10900, DeductibleCents: 900},
+	}
+	if len(sum.ByBuyerPeriod) != len(want) || sum.ByBuyerPeriod[0] != want[0] || sum.ByBuyerPeriod[1] != want[1] {
+		t.Fatalf("unexpected buyer/period groups: %+v", sum.ByBuyerPeriod)
+	}
+	if len(sum.ByBuyer) != 2 || len(sum.ByPeriod) != 2 {
+		t.Fatalf("unexpected groups: buyer=%+v period=%+v", sum.ByBuyer, sum.ByPeriod)
+	}
+}